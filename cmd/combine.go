@@ -26,10 +26,17 @@
 package cmd
 
 import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/klauspost/pgzip"
 	"github.com/spf13/cobra"
 	"github.com/wtsi-ssg/wrstat/v6/combine"
 	"github.com/wtsi-ssg/wrstat/v6/fs"
@@ -37,6 +44,31 @@ import (
 
 const combineStatsOutputFileBasename = "combine.stats.gz"
 const combineLogOutputFileBasename = "combine.log.gz"
+const combineDuplicatesOutputFileBasename = "combine.duplicates"
+const defaultDuplicatesMinSize = 100000000
+const combineShardOutputFileBasenameFormat = "combine.stats.shard%d.gz"
+const combineWritableOutputFileBasename = "combine.writable"
+const combineUIDOutputFileBasenameFormat = "combine.stats.uid%s.gz"
+const combineUIDMaxOpen = 100
+const combineOutputFilePerms = 0666
+const combineProgressFrequency = 1 * time.Minute
+const combineExtensionsOutputFileBasename = "combine.extensions"
+const combineIndexOutputFileBasename = "combine.stats.index"
+
+// options for this cmd.
+var (
+	combineDuplicates        int
+	combineDuplicatesMinSize int64
+	combineShards            int
+	combineWritable          bool
+	combineSplitByUID        bool
+	combineSummaryLine       bool
+	combineProgress          bool
+	combineExtensions        int
+	combineIndex             bool
+	combineRoot              string
+	combineSubDirs           bool
+)
 
 // combineCmd represents the combine command.
 var combineCmd = &cobra.Command{
@@ -48,8 +80,76 @@ Within the given output directory, all the 'wrstat stat' *.stats files produced
 following an invocation of 'wrstat walk' will be concatenated, compressed and
 placed at the root of the output directory in a file called 'combine.stats.gz'.
 
+If --subdirs is supplied, the *.stats (and *.log and *.modes) files in the
+immediate subdirectories of the output directory are also combined, as needed
+for the output of 'wrstat multi --root_depth_split'.
+
+If only some of the *.stats files were made using 'wrstat stat --dir_blocks',
+their directory sizes would mean different things, so this command will fail
+without combining them. Rerun the walk with consistent options.
+
 The same applies to the *.log files, being called 'combine.log.gz'.
 
+If --index is supplied, 'combine.stats.gz' is written as a series of
+concatenated gzip members, one per top-level entry within the walked directory
+(the file still decompresses as normal), and an index of those members is
+written to 'combine.stats.index'. Each line of the index is tab separated: the
+quoted path of the top-level entry (with a trailing slash for directories), and
+the byte offset and length of its member within 'combine.stats.gz'. This lets
+you decompress just the stats for a single top-level directory. The directory
+that was walked must be given with --root, and this will fail if any of the
+stats are not within it.
+
+If --duplicates is greater than zero, a report of possible unauthorised copies
+is also written to 'combine.duplicates': regular file basenames that appear at
+least --duplicates times with the same size, where that size is at least
+--duplicates_min_size bytes. Each line is tab separated: the quoted basename,
+the size, the number of occurrences, then the quoted path of each occurrence.
+(Matching files are sorted by basename using the external 'sort' command, which
+needs temporary space in the given directory, so don't set
+--duplicates_min_size too low on very large trees.)
+
+If --extensions is greater than zero, a report of regular file extensions is
+also written to 'combine.extensions', to help with long-tail analysis. Each line
+is tab separated: the quoted lowercased extension (without the leading dot;
+blank for files with no extension), the number of files and their total size in
+bytes. Only the --extensions largest extensions by total size are reported
+individually, with the rest summed in to a final line with an unquoted
+extension of 'other'. (Only the first 100,000 distinct extensions are tracked,
+with files having any other extension also counted as 'other'.)
+
+If --shard is greater than 1, the combined stats are additionally split into
+that many files named 'combine.stats.shard[n].gz' (n counting from 0). Each
+top-level directory within the walked directory (and everything beneath it) is
+assigned to a shard by a hash of its name, so every shard is a self-contained,
+sorted set of whole subtrees. This means each shard can be loaded in to its own
+separate database, and queries for a given subtree only need the database made
+from the shard containing it. The directory that was walked must be given with
+--root, and this will fail if any of the stats are not within it.
+
+If --split_by_uid is supplied, the combined stats are additionally split in to
+a file per UID named 'combine.stats.uid[uid].gz', each containing just the
+lines for entries owned by that UID. (At most 100 of these files are held open
+at once; others are closed and later appended to as needed, so a file may
+contain multiple gzip members.)
+
+If --writable is supplied, the *.modes files produced by 'wrstat stat --modes'
+are summarised in to 'combine.writable': a report of each directory that
+directly contains files (not directories or symlinks) that are group or world
+writable. Each line is tab separated: the quoted directory, the number of group
+writable files and the number of world writable files. A directory's line comes
+after the lines of its subdirectories.
+
+If --progress is supplied, the number of *.stats files fully merged so far and
+the number of compressed bytes written to 'combine.stats.gz' so far are logged
+every minute while the stats are combined, and once more when done.
+
+If --summary_line is supplied, once the combined stats file has been written a
+single line of JSON is printed to STDOUT, giving the total number of
+directories ("dirs"), the total number of other entries ("files") and the total
+size of those other entries ("bytes") found in it, eg.
+{"dirs":10,"files":200,"bytes":123456}
+
 NB: only call this by adding it to wr with a dependency on the dependency group
 you supplied 'wrstat walk'.`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -57,6 +157,10 @@ you supplied 'wrstat walk'.`,
 			die("exactly 1 'wrstat walk' output directory must be supplied")
 		}
 
+		if (combineShards > 1 || combineIndex) && combineRoot == "" {
+			die("--root is required with --shard and --index")
+		}
+
 		sourceDir, err := filepath.Abs(args[0])
 		if err != nil {
 			die("could not get the absolute path to [%s]: %s", args[0], err)
@@ -69,6 +173,26 @@ you supplied 'wrstat walk'.`,
 			defer wg.Done()
 
 			concatenateAndCompressStatsFiles(sourceDir)
+
+			if combineSummaryLine {
+				printSummaryLine(sourceDir)
+			}
+
+			if combineDuplicates > 0 {
+				reportDuplicateFilenames(sourceDir, combineDuplicates, combineDuplicatesMinSize)
+			}
+
+			if combineExtensions > 0 {
+				reportExtensions(sourceDir, combineExtensions)
+			}
+
+			if combineShards > 1 {
+				shardCombinedStats(sourceDir, combineShards, combineRoot)
+			}
+
+			if combineSplitByUID {
+				splitCombinedStatsByUID(sourceDir)
+			}
 		}()
 
 		wg.Add(1)
@@ -78,30 +202,139 @@ you supplied 'wrstat walk'.`,
 			concatenateAndCompressLogFiles(sourceDir)
 		}()
 
+		if combineWritable {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				reportWritableFiles(sourceDir)
+			}()
+		}
+
 		wg.Wait()
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(combineCmd)
+
+	// flags specific to this sub-command
+	combineCmd.Flags().IntVar(&combineDuplicates, "duplicates", 0,
+		"report basenames seen at least this many times with the same size (0 to disable)")
+	combineCmd.Flags().Int64Var(&combineDuplicatesMinSize, "duplicates_min_size", defaultDuplicatesMinSize,
+		"minimum file size in bytes to consider for --duplicates")
+	combineCmd.Flags().BoolVar(&combineIndex, "index", false,
+		"write the combined stats so they can be read per top-level directory using an index")
+	combineCmd.Flags().IntVar(&combineExtensions, "extensions", 0,
+		"report counts and sizes for this many file extensions (0 to disable)")
+	combineCmd.Flags().IntVar(&combineShards, "shard", 0,
+		"also split the combined stats in to this many shards by top-level directory")
+	combineCmd.Flags().BoolVar(&combineSubDirs, "subdirs", false,
+		"also combine the files in immediate subdirectories of the output directory")
+	combineCmd.Flags().StringVar(&combineRoot, "root", "",
+		"the directory that was walked, needed by --shard and --index")
+	combineCmd.Flags().BoolVar(&combineSplitByUID, "split_by_uid", false,
+		"also split the combined stats in to a file per UID")
+	combineCmd.Flags().BoolVar(&combineWritable, "writable", false,
+		"report counts of group and world writable files per directory from *.modes files")
+	combineCmd.Flags().BoolVar(&combineProgress, "progress", false,
+		"periodically log the progress of combining the stats files")
+	combineCmd.Flags().BoolVar(&combineSummaryLine, "summary_line", false,
+		"print a JSON summary of the combined stats to STDOUT")
+}
+
+// findOpenAndCreate finds the files in sourceDir (and its immediate
+// subdirectories if --subdirs was supplied) with the given suffix and opens
+// them, and creates an output file in sourceDir with the given basename.
+func findOpenAndCreate(sourceDir, suffix, basename string) ([]*os.File, *os.File, error) {
+	if combineSubDirs {
+		return fs.FindInDirAndSubDirsOpenAndCreate(sourceDir, sourceDir, suffix, basename)
+	}
+
+	return fs.FindOpenAndCreate(sourceDir, sourceDir, suffix, basename)
 }
 
 // concatenateAndCompressStatsFiles finds and concatenates the stats files and
 // compresses the output.
 func concatenateAndCompressStatsFiles(sourceDir string) {
-	inputFiles, outputFile, err := fs.FindOpenAndCreate(sourceDir, sourceDir, statOutputFileSuffix,
-		combineStatsOutputFileBasename)
+	inputFiles, outputFile, err := findOpenAndCreate(sourceDir, statOutputFileSuffix, combineStatsOutputFileBasename)
 	if err != nil {
 		die("failed to find, open or create stats files: %s", err)
 	}
 
-	if err = combine.StatFiles(inputFiles, outputFile); err != nil {
+	checkConsistentDirBlocks(inputFiles)
+
+	if combineIndex {
+		concatenateCompressAndIndexStatsFiles(sourceDir, inputFiles, outputFile)
+
+		return
+	}
+
+	if combineProgress {
+		err = combine.StatFilesWithProgress(inputFiles, outputFile, combineProgressFrequency, logCombineProgress)
+	} else {
+		err = combine.StatFiles(inputFiles, outputFile)
+	}
+
+	if err != nil {
 		die("failed to concatenate and compress stats files (err: %s)", err)
 	}
 
 	closeFiles(inputFiles, outputFile)
 }
 
+// concatenateCompressAndIndexStatsFiles is like
+// concatenateAndCompressStatsFiles(), but writes the output with a gzip member
+// per top-level entry within combineRoot, and writes an index of the members to
+// a file in the given directory.
+func concatenateCompressAndIndexStatsFiles(sourceDir string, inputFiles []*os.File, outputFile *os.File) {
+	index, err := fs.CreateOutputFileInDir(sourceDir, combineIndexOutputFileBasename)
+	if err != nil {
+		die("failed to create index file: %s", err)
+	}
+
+	if combineProgress {
+		err = combine.StatFilesWithIndexAndProgress(inputFiles, outputFile, index, combineRoot,
+			combineProgressFrequency, logCombineProgress)
+	} else {
+		err = combine.StatFilesWithIndex(inputFiles, outputFile, index, combineRoot)
+	}
+
+	if err != nil {
+		die("failed to concatenate, compress and index stats files (err: %s)", err)
+	}
+
+	if err = index.Close(); err != nil {
+		die("failed to close index file (err: %s)", err)
+	}
+
+	closeFiles(inputFiles, outputFile)
+}
+
+// checkConsistentDirBlocks dies if some but not all of the given stats files
+// were made with 'wrstat stat --dir_blocks'.
+func checkConsistentDirBlocks(statsFiles []*os.File) {
+	var marked int
+
+	for _, f := range statsFiles {
+		marker := strings.TrimSuffix(f.Name(), statOutputFileSuffix) + statDirBlocksFileSuffix
+
+		if _, err := os.Stat(marker); err == nil {
+			marked++
+		}
+	}
+
+	if marked > 0 && marked < len(statsFiles) {
+		die("%d of %d stats files were made with --dir_blocks; refusing to combine "+
+			"inconsistent directory sizes (rerun the walk with consistent options)", marked, len(statsFiles))
+	}
+}
+
+// logCombineProgress is a combine.ProgressFunc that logs the progress.
+func logCombineProgress(filesDone, filesTotal int, bytesWritten int64) {
+	info("combined %d of %d stats files; %d bytes written", filesDone, filesTotal, bytesWritten)
+}
+
 func closeFiles(inputFiles []*os.File, outputFile *os.File) {
 	for _, file := range inputFiles {
 		file.Close()
@@ -115,8 +348,7 @@ func closeFiles(inputFiles []*os.File, outputFile *os.File) {
 // concatenateAndCompressLogFiles finds and merges the log files and compresses the
 // output.
 func concatenateAndCompressLogFiles(sourceDir string) {
-	inputFiles, outputFile, err := fs.FindOpenAndCreate(sourceDir,
-		sourceDir, statLogOutputFileSuffix, combineLogOutputFileBasename)
+	inputFiles, outputFile, err := findOpenAndCreate(sourceDir, statLogOutputFileSuffix, combineLogOutputFileBasename)
 	if err != nil {
 		die("failed to find, open or create log files: %s", err)
 	}
@@ -127,3 +359,195 @@ func concatenateAndCompressLogFiles(sourceDir string) {
 
 	closeFiles(inputFiles, outputFile)
 }
+
+// openCombinedStats opens the combined stats file in the given directory for
+// decompressed reading. Call the returned function when you're done reading.
+func openCombinedStats(sourceDir string) (io.Reader, func()) {
+	f, err := os.Open(filepath.Join(sourceDir, combineStatsOutputFileBasename))
+	if err != nil {
+		die("failed to open combined stats file: %s", err)
+	}
+
+	r, err := pgzip.NewReader(f)
+	if err != nil {
+		die("failed to decompress combined stats file: %s", err)
+	}
+
+	return r, func() {
+		r.Close()
+		f.Close()
+	}
+}
+
+// printSummaryLine reads the combined stats file in the given directory and
+// prints a summary of it as JSON to STDOUT.
+func printSummaryLine(sourceDir string) {
+	r, done := openCombinedStats(sourceDir)
+	defer done()
+
+	summary, err := combine.Summarise(r)
+	if err != nil {
+		die("failed to summarise combined stats file: %s", err)
+	}
+
+	line, err := json.Marshal(summary)
+	if err != nil {
+		die("failed to encode summary: %s", err)
+	}
+
+	fmt.Println(string(line))
+}
+
+// reportDuplicateFilenames reads the combined stats file in the given directory
+// and writes a report of duplicate filenames to a file in the same directory.
+func reportDuplicateFilenames(sourceDir string, minCount int, minSize int64) {
+	r, done := openCombinedStats(sourceDir)
+	defer done()
+
+	output, err := fs.CreateOutputFileInDir(sourceDir, combineDuplicatesOutputFileBasename)
+	if err != nil {
+		die("failed to create duplicates file: %s", err)
+	}
+
+	if err = combine.DuplicateFilenames(r, output, minSize, minCount, sourceDir); err != nil {
+		die("failed to report duplicate filenames: %s", err)
+	}
+
+	if err = output.Close(); err != nil {
+		die("failed to close duplicates file: %s", err)
+	}
+}
+
+// reportExtensions reads the combined stats file in the given directory and
+// writes a report of the topK file extensions to a file in the same directory.
+func reportExtensions(sourceDir string, topK int) {
+	r, done := openCombinedStats(sourceDir)
+	defer done()
+
+	output, err := fs.CreateOutputFileInDir(sourceDir, combineExtensionsOutputFileBasename)
+	if err != nil {
+		die("failed to create extensions file: %s", err)
+	}
+
+	if err = combine.ExtensionTally(r, output, topK); err != nil {
+		die("failed to report file extensions: %s", err)
+	}
+
+	if err = output.Close(); err != nil {
+		die("failed to close extensions file: %s", err)
+	}
+}
+
+// shardCombinedStats reads the combined stats file in the given directory and
+// splits it in to n compressed shard files in the same directory, by top-level
+// directory beneath the given walked root.
+func shardCombinedStats(sourceDir string, n int, root string) {
+	r, done := openCombinedStats(sourceDir)
+	defer done()
+
+	files := make([]*os.File, n)
+	compressors := make([]io.WriteCloser, n)
+	outputs := make([]io.Writer, n)
+
+	for i := range files {
+		f, err := fs.CreateOutputFileInDir(sourceDir, fmt.Sprintf(combineShardOutputFileBasenameFormat, i))
+		if err != nil {
+			die("failed to create shard file: %s", err)
+		}
+
+		files[i] = f
+		compressors[i] = pgzip.NewWriter(f)
+		outputs[i] = compressors[i]
+	}
+
+	if err := combine.ShardStats(r, outputs, root); err != nil {
+		die("failed to shard combined stats file: %s", err)
+	}
+
+	closeCompressedFiles(files, compressors)
+}
+
+// reportWritableFiles reads the modes files in the given directory and writes
+// a report of writable file counts per directory to a file in the same
+// directory.
+func reportWritableFiles(sourceDir string) {
+	inputFiles, outputFile, err := findOpenAndCreate(sourceDir, statModesFileSuffix, combineWritableOutputFileBasename)
+	if err != nil {
+		die("failed to find, open or create modes files: %s", err)
+	}
+
+	inputs := make([]io.Reader, len(inputFiles))
+
+	for i, f := range inputFiles {
+		inputs[i] = f
+	}
+
+	if err = combine.WritableCounts(inputs, outputFile); err != nil {
+		die("failed to report writable files: %s", err)
+	}
+
+	closeFiles(inputFiles, outputFile)
+}
+
+// splitCombinedStatsByUID reads the combined stats file in the given directory
+// and splits it in to a compressed file per UID in the same directory.
+func splitCombinedStatsByUID(sourceDir string) {
+	r, done := openCombinedStats(sourceDir)
+	defer done()
+
+	err := combine.SplitStatsByUID(r, combineUIDMaxOpen, func(uid string, appending bool) (io.WriteCloser, error) {
+		return openUIDOutput(filepath.Join(sourceDir, fmt.Sprintf(combineUIDOutputFileBasenameFormat, uid)), appending)
+	})
+	if err != nil {
+		die("failed to split combined stats file by uid: %s", err)
+	}
+}
+
+// gzipFile is an io.WriteCloser that writes a gzip member to a file, and closes
+// the file when closed.
+type gzipFile struct {
+	*gzip.Writer
+	f *os.File
+}
+
+// openUIDOutput creates the file at the given path, or opens it for appending,
+// and returns a gzipFile that writes a new gzip member to it. We use gzip
+// instead of pgzip here, since we might have many outputs open at once.
+func openUIDOutput(path string, appending bool) (io.WriteCloser, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appending {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(path, flags, combineOutputFilePerms)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gzipFile{Writer: gzip.NewWriter(f), f: f}, nil
+}
+
+// Close closes the gzip member and then the file.
+func (g *gzipFile) Close() error {
+	if err := g.Writer.Close(); err != nil {
+		g.f.Close()
+
+		return err
+	}
+
+	return g.f.Close()
+}
+
+// closeCompressedFiles closes the given compressors and then their
+// corresponding files.
+func closeCompressedFiles(files []*os.File, compressors []io.WriteCloser) {
+	for i, f := range files {
+		if err := compressors[i].Close(); err != nil {
+			die("failed to compress %s: %s", f.Name(), err)
+		}
+
+		if err := f.Close(); err != nil {
+			die("failed to close %s: %s", f.Name(), err)
+		}
+	}
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/adhocore/gronx"
 	"github.com/adhocore/gronx/pkg/tasker"
 	"github.com/spf13/cobra"
+	"github.com/wtsi-ssg/wrstat/v6/scheduler"
 )
 
 // options for this cmd.
@@ -107,11 +108,31 @@ func init() {
 	cronCmd.Flags().IntVarP(&multiStatJobs, "num_stat_jobs", "j",
 		0, "force a specific number of parallel stat jobs (ignore -n if above 0)")
 	cronCmd.Flags().StringVar(&multiCh, "ch", "", "passed through to 'wrstat walk'")
+	cronCmd.Flags().BoolVar(&multiDirBlocks, "dir_blocks", false, "passed through to 'wrstat walk'")
+	cronCmd.Flags().StringVar(&multiUIDMap, "uid_map", "", "passed through to 'wrstat walk'")
+	cronCmd.Flags().StringVar(&multiGIDMap, "gid_map", "", "passed through to 'wrstat walk'")
+	cronCmd.Flags().StringSliceVar(&multiPruneDirs, "prune_dirs", nil, "passed through to 'wrstat walk'")
 	cronCmd.Flags().StringVar(&forcedQueue, "queue", "", "force a particular queue to be used when scheduling jobs")
 	cronCmd.Flags().StringVar(&queuesToAvoid, "queues_avoid", "",
 		"force queues that include a substring from this comma-separated list to be avoided when scheduling jobs")
 	cronCmd.Flags().IntVarP(&maxMem, "max_mem", "m",
 		defaultMaxRAM, "maximum MBs to reserve for any job")
+	cronCmd.Flags().DurationVar(&multiWalkTime, "walk_time", walkTime, "time to reserve for each walk job")
+	cronCmd.Flags().IntVar(&multiWalkRAM, "walk_ram", walkRAM, "MBs to reserve for each walk job")
+	cronCmd.Flags().DurationVar(&multiCombineTime, "combine_time", combineTime, "time to reserve for each combine job")
+	cronCmd.Flags().IntVar(&multiCombineRAM, "combine_ram", combineRAM, "MBs to reserve for each combine job")
+	cronCmd.Flags().Uint8Var(&multiRetries, "retries", scheduler.DefaultRetries,
+		"number of times to retry failed jobs, including the stat jobs scheduled by walk")
+	cronCmd.Flags().StringVar(&multiNotifyCmd, "notify_cmd", "", "command to run once the final outputs are ready")
+	cronCmd.Flags().IntVar(&parallelWalks, "parallel_walks", 0,
+		"maximum number of walk jobs to run at once (0 for unlimited)")
+	cronCmd.Flags().StringVar(&multiJobsOutput, "jobs_output", "",
+		"file to record the submitted jobs in as JSON (replaced each run)")
+	cronCmd.Flags().BoolVar(&multiJobsAppend, "jobs_output_append", false,
+		"append a JSON line per run to --jobs_output instead of replacing it")
+	cronCmd.Flags().IntVar(&rootDepthSplit, "root_depth_split", 0,
+		"walk each subdirectory this deep in each directory of interest separately (0 to disable; "+
+			"those directories are read without sudo)")
 	cronCmd.Flags().StringVarP(&crontab, "crontab", "c",
 		"0 17 * * *",
 		"crontab describing when to run, first 5 columns only")
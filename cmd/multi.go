@@ -26,9 +26,14 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/VertebrateResequencing/wr/jobqueue"
@@ -43,18 +48,37 @@ const (
 	combineTime   = 40 * time.Minute
 	combineRAM    = 800
 	defaultMaxRAM = 42000
+
+	tidyDepGroupPrefix  = "tidy-"
+	walkLimitGroup      = "wrstat-walk"
+	shallowWalkBasename = "walk.shallow"
+	jobsOutputPerms     = 0666
 )
 
 // options for this cmd.
 var (
-	workDir       string
-	finalDir      string
-	multiInodes   int
-	multiStatJobs int
-	multiCh       string
-	forcedQueue   string
-	queuesToAvoid string
-	maxMem        int
+	workDir          string
+	finalDir         string
+	multiInodes      int
+	multiStatJobs    int
+	multiCh          string
+	forcedQueue      string
+	queuesToAvoid    string
+	maxMem           int
+	multiWalkTime    time.Duration
+	multiWalkRAM     int
+	multiCombineTime time.Duration
+	multiCombineRAM  int
+	multiRetries     uint8
+	multiDirBlocks   bool
+	multiUIDMap      string
+	multiGIDMap      string
+	multiNotifyCmd   string
+	parallelWalks    int
+	rootDepthSplit   int
+	multiPruneDirs   []string
+	multiJobsOutput  string
+	multiJobsAppend  bool
 )
 
 // multiCmd represents the multi command.
@@ -103,7 +127,38 @@ The output files will be given the same user:group ownership and
 user,group,other read & write permissions as the --final_output directory.
 
 Finally, the unique subdirectory of --working_directory that was created is
-deleted.`,
+deleted.
+
+By default all the walk jobs can run at the same time, which may overload the
+filesystem if you supply many directories of interest. Supplying
+--parallel_walks limits how many walk jobs can run at once (across all multi
+and cron invocations using the same wr manager).
+
+A single very large directory of interest can make for a single very long
+running walk job. If you supply --root_depth_split, each directory of interest
+is instead split up in to its subdirectories that are that many levels deep,
+and a separate walk job is run for each of them, outputting to its own numbered
+subdirectory of the directory of interest's output directory. The entries not
+within those subdirectories are statted by an additional job. There is still
+only a single combine job for the directory of interest: it is run with
+--subdirs, so it merges the stats of every subtree (which are all sorted, so
+can be merged directly) and the final output is the same as without splitting.
+NB: the directories down to that depth are read by this command itself, as the
+user running it, even if you supply --sudo, so you may need to run this as a
+user that can read them.
+
+If you supply --jobs_output, the details of every job this command adds to wr's
+queue are also written to that file as a JSON array, for your records. (The
+file is only replaced once fully written.) If you also supply
+--jobs_output_append, the file is instead appended to with a single line of
+JSON: an object with an "id" (the unique name of the subdirectory of
+--working_directory used by that run) and the array of "jobs". This lets the
+file build up an audit trail over repeated runs, such as those of 'wrstat
+cron', but a line could be left incomplete if writing it fails.
+
+If you supply --notify_cmd, that command will be run (without sudo) as a final
+job once the above tidy job has completed successfully, eg. to send an email
+saying that the final outputs are ready.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		checkMultiArgs()
 		err := doMultiScheduling(args, workDir, forcedQueue, queuesToAvoid, sudo)
@@ -124,10 +179,30 @@ func init() {
 	multiCmd.Flags().IntVarP(&multiStatJobs, "num_stat_jobs", "j",
 		0, "force a specific number of parallel stat jobs (ignore -n if above 0)")
 	multiCmd.Flags().StringVar(&multiCh, "ch", "", "passed through to 'wrstat walk'")
+	multiCmd.Flags().BoolVar(&multiDirBlocks, "dir_blocks", false, "passed through to 'wrstat walk'")
+	multiCmd.Flags().StringVar(&multiUIDMap, "uid_map", "", "passed through to 'wrstat walk'")
+	multiCmd.Flags().StringVar(&multiGIDMap, "gid_map", "", "passed through to 'wrstat walk'")
+	multiCmd.Flags().StringSliceVar(&multiPruneDirs, "prune_dirs", nil, "passed through to 'wrstat walk'")
 	multiCmd.Flags().StringVar(&forcedQueue, "queue", "", "force a particular queue to be used when scheduling jobs")
 	multiCmd.Flags().StringVar(&queuesToAvoid, "queues_avoid", "",
 		"force queues that include a substring from this comma-separated list to be avoided when scheduling jobs")
 	multiCmd.Flags().IntVarP(&maxMem, "max_mem", "m", defaultMaxRAM, "maximum MBs to reserve for any job")
+	multiCmd.Flags().DurationVar(&multiWalkTime, "walk_time", walkTime, "time to reserve for each walk job")
+	multiCmd.Flags().IntVar(&multiWalkRAM, "walk_ram", walkRAM, "MBs to reserve for each walk job")
+	multiCmd.Flags().DurationVar(&multiCombineTime, "combine_time", combineTime, "time to reserve for each combine job")
+	multiCmd.Flags().IntVar(&multiCombineRAM, "combine_ram", combineRAM, "MBs to reserve for each combine job")
+	multiCmd.Flags().Uint8Var(&multiRetries, "retries", scheduler.DefaultRetries,
+		"number of times to retry failed jobs, including the stat jobs scheduled by walk")
+	multiCmd.Flags().StringVar(&multiNotifyCmd, "notify_cmd", "", "command to run once the final outputs are ready")
+	multiCmd.Flags().IntVar(&parallelWalks, "parallel_walks", 0,
+		"maximum number of walk jobs to run at once (0 for unlimited)")
+	multiCmd.Flags().StringVar(&multiJobsOutput, "jobs_output", "",
+		"file to record the submitted jobs in as JSON")
+	multiCmd.Flags().BoolVar(&multiJobsAppend, "jobs_output_append", false,
+		"append a JSON line per run to --jobs_output instead of replacing it")
+	multiCmd.Flags().IntVar(&rootDepthSplit, "root_depth_split", 0,
+		"walk each subdirectory this deep in each directory of interest separately (0 to disable; "+
+			"those directories are read without sudo)")
 }
 
 // checkMultiArgs ensures we have the required args for the multi sub-command.
@@ -146,6 +221,8 @@ func doMultiScheduling(args []string, workDir, forcedQueue, queuesToAvoid string
 	s, d := newScheduler(workDir, forcedQueue, queuesToAvoid, sudo)
 	defer d()
 
+	s.SetRetries(multiRetries)
+
 	unique := scheduler.UniqueString()
 	outputRoot := filepath.Join(workDir, unique)
 
@@ -154,19 +231,92 @@ func doMultiScheduling(args []string, workDir, forcedQueue, queuesToAvoid string
 		return err
 	}
 
-	scheduleWalkJobs(outputRoot, args, unique, multiStatJobs, multiInodes, multiCh, forcedQueue, queuesToAvoid, s)
-	scheduleTidyJob(outputRoot, finalDir, unique, s)
+	jobs := scheduleWalkJobs(outputRoot, args, unique, multiStatJobs, multiInodes, multiCh,
+		forcedQueue, queuesToAvoid, s)
+	jobs = append(jobs, scheduleTidyJob(outputRoot, finalDir, unique, multiNotifyCmd, s)...)
+
+	switch {
+	case multiJobsOutput == "":
+		return nil
+	case multiJobsAppend:
+		return appendJobsOutput(multiJobsOutput, jobsRecord{ID: unique, Jobs: jobs})
+	default:
+		return writeJobsOutput(multiJobsOutput, jobs)
+	}
+}
+
+// writeJobsOutput writes the given jobs as a JSON array to the given path, via
+// a temporary file in the same directory that is synced and then renamed to
+// path once complete.
+func writeJobsOutput(path string, jobs []*jobqueue.Job) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	if err = writeAndSync(f, jobs); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// writeAndSync writes the given jobs as JSON to the given file, and syncs it.
+func writeAndSync(f *os.File, jobs []*jobqueue.Job) error {
+	if err := json.NewEncoder(f).Encode(jobs); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// jobsRecord is a line of --jobs_output in --jobs_output_append mode,
+// detailing the jobs added by a run.
+type jobsRecord struct {
+	ID   string          `json:"id"`
+	Jobs []*jobqueue.Job `json:"jobs"`
+}
+
+// appendJobsOutput appends the given record as a line of JSON to the given
+// path, creating it if necessary.
+func appendJobsOutput(path string, record jobsRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, jobsOutputPerms)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+
+		return err
+	}
 
-	return nil
+	return f.Close()
 }
 
 // scheduleWalkJobs adds a 'wrstat walk' job to wr's queue for each desired
-// path. The second scheduler is used to add combine jobs, which need a memory
-// override.
+// path, along with their combine jobs (and any stat jobs needed due to
+// --root_depth_split). Returns all the jobs added.
 func scheduleWalkJobs(outputRoot string, desiredPaths []string, unique string,
 	numStatJobs, inodesPerStat int, yamlPath, queue, queuesAvoid string, s *scheduler.Scheduler,
-) {
-	walkJobs := make([]*jobqueue.Job, len(desiredPaths))
+) []*jobqueue.Job {
+	var statJobs []*jobqueue.Job
+
+	walkJobs := make([]*jobqueue.Job, 0, len(desiredPaths))
 	combineJobs := make([]*jobqueue.Job, len(desiredPaths))
 
 	cmd := buildWalkCommand(s, numStatJobs, inodesPerStat, yamlPath, queue, queuesAvoid)
@@ -177,20 +327,170 @@ func scheduleWalkJobs(outputRoot string, desiredPaths []string, unique string,
 		thisUnique := scheduler.UniqueString()
 		outDir := filepath.Join(outputRoot, filepath.Base(path), thisUnique)
 
-		walkJobs[i] = s.NewJob(fmt.Sprintf("%s -d %s -o %s -i %s %s",
-			cmd, thisUnique, outDir, statRepGrp(path, unique), path),
-			walkRepGrp(path, unique), "wrstat-walk", thisUnique, "", reqWalk)
+		walks, stats := newWalkJobs(cmd, path, outDir, thisUnique, unique, reqWalk, s)
+		walkJobs = append(walkJobs, walks...)
+		statJobs = append(statJobs, stats...)
 
-		combineJobs[i] = s.NewJob(fmt.Sprintf("%s combine %s", s.Executable(), outDir),
+		combineJobs[i] = s.NewJob(fmt.Sprintf("%s combine %s%s", s.Executable(), combineSubDirsArg(), outDir),
 			combineRepGrp(path, unique), "wrstat-combine", unique, thisUnique, reqCombine)
 	}
 
-	addJobsToQueue(s, walkJobs)
-	addJobsToQueue(s, combineJobs)
+	var added []*jobqueue.Job
+
+	if len(statJobs) > 0 {
+		added = addJobsToQueue(s, statJobs)
+	}
+
+	added = append(added, addJobsToQueue(s, walkJobs)...)
+
+	return append(added, addJobsToQueue(s, combineJobs)...)
+}
+
+// combineSubDirsArg returns the --subdirs arg for combine if
+// --root_depth_split is in effect, since the subtree walks output to
+// subdirectories.
+func combineSubDirsArg() string {
+	if rootDepthSplit < 1 {
+		return ""
+	}
+
+	return "--subdirs "
+}
+
+// newWalkJobs returns a walk job for the given path that will output to outDir,
+// with its stat jobs in the thisUnique dep group.
+//
+// If --root_depth_split is in effect, instead returns a walk job for each
+// subdirectory of path at that depth, each outputting to a numbered
+// subdirectory of outDir. The other entries in path are written to a walk file
+// in outDir, and a stat job in the thisUnique dep group is also returned for
+// it.
+func newWalkJobs(cmd, path, outDir, thisUnique, unique string, reqWalk *jqs.Requirements,
+	s *scheduler.Scheduler,
+) ([]*jobqueue.Job, []*jobqueue.Job) {
+	if rootDepthSplit < 1 {
+		return []*jobqueue.Job{newWalkJob(cmd, path, outDir, thisUnique, unique, reqWalk, s)}, nil
+	}
+
+	subtrees, shallow, err := splitAtDepth(path, rootDepthSplit)
+	if err != nil {
+		die("failed to split %s at depth %d: %s", path, rootDepthSplit, err)
+	}
+
+	shallowWalkPath := writeShallowWalkFile(outDir, shallow)
+	statJobs := newStatJobs([]string{shallowWalkPath}, thisUnique, statRepGrp(path, unique), multiStatArgs(multiCh), s)
+
+	jobs := make([]*jobqueue.Job, len(subtrees))
+
+	for i, subtree := range subtrees {
+		jobs[i] = newWalkJob(cmd, subtree, filepath.Join(outDir, strconv.Itoa(i+1)), thisUnique, unique, reqWalk, s)
+	}
+
+	return jobs, statJobs
+}
+
+// newWalkJob returns a walk job for the given path that will output to outDir,
+// with its stat jobs in the thisUnique dep group.
+func newWalkJob(cmd, path, outDir, thisUnique, unique string, reqWalk *jqs.Requirements,
+	s *scheduler.Scheduler,
+) *jobqueue.Job {
+	job := s.NewJob(fmt.Sprintf("%s -d %s -o %s -i %s %s",
+		cmd, thisUnique, outDir, statRepGrp(path, unique), path),
+		walkRepGrp(path, unique), "wrstat-walk", thisUnique, "", reqWalk)
+	job.LimitGroups = walkLimitGroups(parallelWalks)
+
+	return job
+}
+
+// splitAtDepth reads the directories in dir down to the given depth, returning
+// the paths of the directories at that depth (which should each be walked), and
+// the sorted paths of every other entry at or above that depth, including dir
+// itself. Directory paths have a trailing slash, like walk's output.
+func splitAtDepth(dir string, depth int) ([]string, []string, error) {
+	dir = strings.TrimSuffix(dir, "/") + "/"
+	shallow := []string{dir}
+
+	subtrees, err := appendEntriesToDepth(dir, depth, nil, &shallow)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Strings(subtrees)
+	sort.Strings(shallow)
+
+	return subtrees, shallow, nil
+}
+
+// appendEntriesToDepth appends the directories depth levels below dir to
+// subtrees, and other entries to shallow.
+func appendEntriesToDepth(dir string, depth int, subtrees []string, shallow *[]string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		path := dir + entry.Name()
+
+		switch {
+		case !entry.IsDir():
+			*shallow = append(*shallow, path)
+		case slices.Contains(multiPruneDirs, entry.Name()):
+			*shallow = append(*shallow, path+"/")
+		case depth == 1:
+			subtrees = append(subtrees, path+"/")
+		default:
+			*shallow = append(*shallow, path+"/")
+
+			if subtrees, err = appendEntriesToDepth(path+"/", depth-1, subtrees, shallow); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return subtrees, nil
+}
+
+// writeShallowWalkFile writes the given paths quoted to a walk file in outDir,
+// returning the path to the file.
+func writeShallowWalkFile(outDir string, paths []string) string {
+	if err := os.MkdirAll(outDir, userGroupPerm); err != nil {
+		die("failed to create output directory: %s", err)
+	}
+
+	walkPath := filepath.Join(outDir, shallowWalkBasename)
+
+	f, err := os.Create(walkPath)
+	if err != nil {
+		die("failed to create walk file: %s", err)
+	}
+
+	for _, path := range paths {
+		if _, err = f.WriteString(strconv.Quote(path) + "\n"); err != nil {
+			die("failed to write walk file: %s", err)
+		}
+	}
+
+	if err = f.Close(); err != nil {
+		die("failed to close walk file: %s", err)
+	}
+
+	return walkPath
+}
+
+// walkLimitGroups returns the limit groups that walk jobs should have so that
+// no more than n of them run at once. Returns nil if n is less than 1.
+func walkLimitGroups(n int) []string {
+	if n < 1 {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("%s:%d", walkLimitGroup, n)}
 }
 
 // buildWalkCommand builds a wrstat walk command line based on the given n,
-// yaml path, queue, and if sudo is in effect.
+// yaml path, queue, id maps, prune dirs, retries, and if --dir_blocks and sudo
+// are in effect.
 func buildWalkCommand(s *scheduler.Scheduler, numStatJobs, inodesPerStat int,
 	yamlPath, queue, queuesAvoid string) string {
 	cmd := s.Executable() + " walk "
@@ -201,9 +501,7 @@ func buildWalkCommand(s *scheduler.Scheduler, numStatJobs, inodesPerStat int,
 		cmd += fmt.Sprintf("-n %d ", inodesPerStat)
 	}
 
-	if yamlPath != "" {
-		cmd += fmt.Sprintf("--ch %s ", yamlPath)
-	}
+	cmd += multiStatArgs(yamlPath)
 
 	if queue != "" {
 		cmd += fmt.Sprintf("--queue %s ", queue)
@@ -213,6 +511,14 @@ func buildWalkCommand(s *scheduler.Scheduler, numStatJobs, inodesPerStat int,
 		cmd += fmt.Sprintf("--queues_avoid %s ", queuesAvoid)
 	}
 
+	if len(multiPruneDirs) > 0 {
+		cmd += fmt.Sprintf("--prune_dirs %s ", strings.Join(multiPruneDirs, ","))
+	}
+
+	if multiRetries != scheduler.DefaultRetries {
+		cmd += fmt.Sprintf("--retries %d ", multiRetries)
+	}
+
 	if sudo {
 		cmd += "--sudo "
 	}
@@ -220,15 +526,41 @@ func buildWalkCommand(s *scheduler.Scheduler, numStatJobs, inodesPerStat int,
 	return cmd
 }
 
-// reqs returns Requirements suitable for walk and combine jobs.
+// multiStatArgs returns the args that get passed through walk to stat: the
+// given yaml for the --ch arg if not blank, and --dir_blocks, --uid_map and
+// --gid_map if set.
+func multiStatArgs(yamlPath string) string {
+	var args string
+
+	if yamlPath != "" {
+		args += fmt.Sprintf("--ch %s ", yamlPath)
+	}
+
+	if multiDirBlocks {
+		args += "--dir_blocks "
+	}
+
+	if multiUIDMap != "" {
+		args += fmt.Sprintf("--uid_map %s ", multiUIDMap)
+	}
+
+	if multiGIDMap != "" {
+		args += fmt.Sprintf("--gid_map %s ", multiGIDMap)
+	}
+
+	return args
+}
+
+// reqs returns Requirements suitable for walk and combine jobs, based on the
+// --walk_* and --combine_* options, capped by --max_mem.
 func reqs() (*jqs.Requirements, *jqs.Requirements) {
 	req := scheduler.DefaultRequirements()
 	reqWalk := req.Clone()
-	reqWalk.Time = walkTime
-	reqWalk.RAM = min(walkRAM, maxMem)
+	reqWalk.Time = multiWalkTime
+	reqWalk.RAM = min(multiWalkRAM, maxMem)
 	reqCombine := req.Clone()
-	reqCombine.Time = combineTime
-	reqCombine.RAM = min(combineRAM, maxMem)
+	reqCombine.Time = multiCombineTime
+	reqCombine.RAM = min(multiCombineRAM, maxMem)
 
 	return reqWalk, reqCombine
 }
@@ -248,9 +580,27 @@ func combineRepGrp(dir, unique string) string {
 // scheduleTidyJob adds a job to wr's queue that for each working directory
 // subdir moves the output to the final location and then deletes the working
 // directory.
-func scheduleTidyJob(outputRoot, finalDir, unique string, s *scheduler.Scheduler) {
-	job := s.NewJob(fmt.Sprintf("%s tidy -f %s -d %s %s", s.Executable(), finalDir, dateStamp(), outputRoot),
-		repGrp("tidy", finalDir, unique), "wrstat-tidy", "", unique, scheduler.DefaultRequirements())
+//
+// If notifyCmd isn't blank, also adds a job that runs it after the tidy job.
+// Returns the jobs added.
+func scheduleTidyJob(outputRoot, finalDir, unique, notifyCmd string, s *scheduler.Scheduler) []*jobqueue.Job {
+	var tidyDepGroup string
+	if notifyCmd != "" {
+		tidyDepGroup = tidyDepGroupPrefix + unique
+	}
+
+	jobs := []*jobqueue.Job{
+		s.NewJob(fmt.Sprintf("%s tidy -f %s -d %s %s", s.Executable(), finalDir, dateStamp(), outputRoot),
+			repGrp("tidy", finalDir, unique), "wrstat-tidy", tidyDepGroup, unique, scheduler.DefaultRequirements()),
+	}
+
+	if notifyCmd != "" {
+		job := s.NewJob("", repGrp("notify", finalDir, unique), "wrstat-notify", "", tidyDepGroup,
+			scheduler.DefaultRequirements())
+		job.Cmd = notifyCmd // set after NewJob() so it doesn't get run with sudo
+
+		jobs = append(jobs, job)
+	}
 
-	addJobsToQueue(s, []*jobqueue.Job{job})
+	return addJobsToQueue(s, jobs)
 }
@@ -186,17 +186,19 @@ func dateStamp() string {
 	return t.Format("20060102")
 }
 
-// addJobsToQueue adds the jobs to wr's queue.
-func addJobsToQueue(s *scheduler.Scheduler, jobs []*jobqueue.Job) {
+// addJobsToQueue adds the jobs to wr's queue, returning them for convenience.
+func addJobsToQueue(s *scheduler.Scheduler, jobs []*jobqueue.Job) []*jobqueue.Job {
 	if runJobs != "" {
 		testPrint(jobs)
 
-		return
+		return jobs
 	}
 
 	if err := s.SubmitJobs(jobs); err != nil {
 		die("failed to add jobs to wr's queue: %s", err)
 	}
+
+	return jobs
 }
 
 func testPrint(jobs []*jobqueue.Job) {
@@ -207,6 +209,8 @@ func testPrint(jobs []*jobqueue.Job) {
 
 func testScheduler(sudo bool) (*scheduler.Scheduler, func()) {
 	s := &scheduler.Scheduler{}
+	s.SetRetries(scheduler.DefaultRetries)
+
 	if sudo {
 		s.EnableSudo()
 	}
@@ -28,6 +28,8 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -38,6 +40,11 @@ import (
 const (
 	reportFrequency         = 10 * time.Minute
 	statOutputFileSuffix    = ".stats"
+	statSymlinksFileSuffix  = ".symlinks"
+	statModesFileSuffix     = ".modes"
+	statNlinksFileSuffix    = ".nlinks"
+	statDirBlocksFileSuffix = ".dirblocks"
+	statStdinInput          = "-"
 	statLogOutputFileSuffix = ".log"
 	lstatTimeout            = 10 * time.Second
 	lstatAttempts           = 3
@@ -46,10 +53,51 @@ const (
 )
 
 var (
-	statDebug bool
-	statCh    string
+	statDebug     bool
+	statCh        string
+	statDirBlocks bool
+	statFailFast  bool
+	statSymlinks  bool
+	statUIDMap    string
+	statGIDMap    string
+	statModes     bool
+	statCanonical bool
+	statOutput    string
+	statTrim      string
+	statAdd       string
+	statNlinks    uint64
 )
 
+// statOptions holds the options that alter what statPathsInFile() does.
+type statOptions struct {
+	// tsvPath, if not empty, is the --ch file detailing chmod and chown
+	// operations to carry out on certain paths.
+	tsvPath string
+
+	// debug makes timings for Lstat calls and other operations be output.
+	debug bool
+
+	// failFast makes us die on the first path that can't be statted, instead
+	// of skipping such paths and logging a count of them.
+	failFast bool
+
+	// canonicalise makes us clean paths before recording them.
+	canonicalise bool
+
+	// symlinks makes us also output a report of symlinks and their targets.
+	symlinks bool
+
+	// modes makes us also output the permission bits of every path.
+	modes bool
+
+	// nlinkThreshold, if greater than zero, makes us also output a report of
+	// non-directories with more hard links than this.
+	nlinkThreshold uint64
+
+	// config alters the stats that get output.
+	config stat.FileOperationConfig
+}
+
 // statCmd represents the stat command.
 var statCmd = &cobra.Command{
 	Use:   "stat",
@@ -60,6 +108,11 @@ Given a file containing a quoted absolute file path per line (eg. as produced
 by 'wrstat walk'), this creates a new file with stats for each of those file
 paths. The new file is named after the input file with a ".stats" suffix.
 
+If the input file is given as '-', paths are instead read from STDIN. In that
+case you must supply --output, which is then used in place of the input file
+path when naming all output files. (You can also supply --output when reading
+from a file, to have the output files named differently.)
+
 The output file format is 11 tab separated columns with the following contents:
 1. Quoted path to the file.
 2. File size in bytes. If this is greater than the number of bytes in blocks
@@ -74,6 +127,7 @@ The output file format is 11 tab separated columns with the following contents:
 8. Filetype:
    'f': regular file
    'l': symbolic link
+   'd': directory
    's': socket
    'b': block special device file
    'c': character special device file
@@ -83,6 +137,13 @@ The output file format is 11 tab separated columns with the following contents:
 10. Number of hard links.
 11. Identifier of the device on which this file resides.
 
+Directories usually report a fixed apparent size (eg. 4096 bytes) regardless of
+how much disk space they actually use. If you supply --dir_blocks, directories
+will instead be given a size of the bytes in their allocated blocks. So that
+'wrstat combine' can refuse to mix output made with and without this option, an
+empty file named after the input file with a ".dirblocks" suffix is also
+created.
+
 If you supply a tsv file to --ch with the following columns:
 directory user group fileperms dirperms
 [where *perms format is rwxrwxrwx for user,group,other, where - means remove the
@@ -100,6 +161,53 @@ permissions and ownership changed if needed.
 (Any changes caused by this will not be reflected in the output file, since
 the chmod and chown operations happen after path's stats are retrieved.)
 
+Paths that can't be statted (eg. due to permission problems, or because they
+were deleted since the walk) are normally skipped and logged, with a count of
+skipped paths logged at the end. If you supply --fail_fast, the first such
+failure will instead cause this command to fail.
+
+If you supply --symlinks, another file named after the input file with a
+".symlinks" suffix is created, listing each symlink found with its target. It
+has 3 tab separated columns: the quoted path of the symlink, the quoted target
+(as returned by readlink), and 'ok' or 'broken' depending on if the target
+exists.
+
+Paths are normally recorded exactly as they appear in the input file. If you
+supply --canonicalise, each path is instead cleaned (removing any '.' and '..'
+components and repeated slashes). Symlinks are not resolved, so that the output
+stays in the same sorted order as the input; supply --canonicalise to 'wrstat
+walk' to have symlinks in the path of the walked directory resolved.
+
+If you supply --modes, another file named after the input file with a ".modes"
+suffix is created, recording the permission bits of every path. It has 3 tab
+separated columns: the quoted path, the filetype (as above, with 'd' for
+directories) and the permission bits (including setuid, setgid and sticky) in
+octal. 'wrstat combine --writable' can summarise these files.
+
+If you supply --nlink_threshold greater than zero, another file named after the
+input file with a ".nlinks" suffix is created, listing each non-directory with
+more hard links than the threshold, to help find hard link hotspots. It has 4
+tab separated columns: the quoted path, the number of hard links, the inode
+number and the device identifier. (Since every hard link to a file will be
+listed, you can group lines by inode and device to find the paths that share
+the same data.)
+
+If you supply files to --uid_map and/or --gid_map, the UIDs and GIDs output
+will be translated according to those files, which should have 2 whitespace
+separated columns: the id as found on disk, and the id to output instead. Ids
+not in the files are output unchanged. The files can have blank lines and
+comment lines that begin with #, which will be ignored. This is useful when the
+filesystem being statted uses a different id namespace to the one you'll use
+the output in.
+
+If the filesystem you are statting will later be mounted at a different path,
+you can supply --trim_prefix and --add_prefix to have the recorded paths
+reflect that: the --trim_prefix directory is replaced with the --add_prefix
+directory at the start of each path in the stats file (the other output files
+are unaffected). Both must be absolute directory paths ending in '/', and any
+path that does not start with --trim_prefix is logged as an error and left out
+of the stats file.
+
 Finally, log messages (including things like warnings and errors while working
 on the above) are stored in another file named after the input file with a
 ".log" suffix.
@@ -109,9 +217,35 @@ on the above) are stored in another file named after the input file with a
 			die("exactly 1 input file should be provided")
 		}
 
-		logToFile(args[0] + statLogOutputFileSuffix)
+		outputPrefix := statOutput
+		if outputPrefix == "" {
+			if args[0] == statStdinInput {
+				die("--output is required when reading from STDIN")
+			}
 
-		statPathsInFile(args[0], statCh, statDebug)
+			outputPrefix = args[0]
+		}
+
+		checkStatPrefixes(statTrim, statAdd)
+
+		logToFile(outputPrefix + statLogOutputFileSuffix)
+
+		statPathsInFile(args[0], outputPrefix, statOptions{
+			tsvPath:        statCh,
+			debug:          statDebug,
+			failFast:       statFailFast,
+			canonicalise:   statCanonical,
+			symlinks:       statSymlinks,
+			modes:          statModes,
+			nlinkThreshold: statNlinks,
+			config: stat.FileOperationConfig{
+				DirBlocks:  statDirBlocks,
+				UIDMap:     loadIDMap(statUIDMap),
+				GIDMap:     loadIDMap(statGIDMap),
+				TrimPrefix: statTrim,
+				AddPrefix:  statAdd,
+			},
+		})
 	},
 }
 
@@ -119,24 +253,145 @@ func init() {
 	RootCmd.AddCommand(statCmd)
 
 	statCmd.Flags().StringVar(&statCh, "ch", "", "tsv file detailing paths to chmod & chown")
+	statCmd.Flags().StringVarP(&statOutput, "output", "o", "",
+		"path to name output files after (default the input file path)")
 	statCmd.Flags().BoolVar(&statDebug, "debug", false, "output Lstat timings")
+	statCmd.Flags().BoolVar(&statDirBlocks, "dir_blocks", false,
+		"report directory sizes as the bytes in their allocated blocks")
+	statCmd.Flags().BoolVar(&statFailFast, "fail_fast", false, "fail on the first path that can't be statted")
+	statCmd.Flags().BoolVar(&statSymlinks, "symlinks", false, "also output a report of symlinks and their targets")
+	statCmd.Flags().BoolVar(&statModes, "modes", false, "also output the permission bits of every path")
+	statCmd.Flags().Uint64Var(&statNlinks, "nlink_threshold", 0,
+		"also output a report of files with more hard links than this (0 to disable)")
+	statCmd.Flags().BoolVar(&statCanonical, "canonicalise", false,
+		"clean paths, removing '.' and '..' components")
+	statCmd.Flags().StringVar(&statUIDMap, "uid_map", "", "file detailing UIDs to translate in the output")
+	statCmd.Flags().StringVar(&statGIDMap, "gid_map", "", "file detailing GIDs to translate in the output")
+	statCmd.Flags().StringVar(&statTrim, "trim_prefix", "", "directory to remove from the start of output paths")
+	statCmd.Flags().StringVar(&statAdd, "add_prefix", "", "directory to replace --trim_prefix with")
 }
 
-// statPathsInFile does the main work.
-func statPathsInFile(inputPath string, tsvPath string, debug bool) {
-	input, err := os.Open(inputPath)
+// checkStatPrefixes dies if the given --trim_prefix and --add_prefix values
+// aren't usable.
+func checkStatPrefixes(trim, add string) {
+	if trim == "" {
+		if add != "" {
+			die("--add_prefix requires --trim_prefix")
+		}
+
+		return
+	}
+
+	for _, prefix := range []string{trim, add} {
+		if !filepath.IsAbs(prefix) || !strings.HasSuffix(prefix, "/") {
+			die("--trim_prefix and --add_prefix must be absolute directories ending in /")
+		}
+	}
+}
+
+// loadIDMap parses the given id map file, returning nil if path is blank.
+func loadIDMap(path string) stat.IDMap {
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.Open(path)
 	if err != nil {
-		die("failed to open input file: %s", err)
+		die("failed to open id map file: %s", err)
 	}
 
+	defer f.Close()
+
+	m, err := stat.ParseIDMap(f)
+	if err != nil {
+		die("failed to parse id map file %s: %s", path, err)
+	}
+
+	return m
+}
+
+// statPathsInFile does the main work, naming output files after outputPrefix.
+func statPathsInFile(inputPath, outputPrefix string, opts statOptions) {
+	input := openStatInput(inputPath)
+
 	defer func() {
-		err = input.Close()
-		if err != nil {
+		if err := input.Close(); err != nil {
 			warn("failed to close input file: %s", err)
 		}
 	}()
 
-	scanAndStatInput(input, createStatOutputFile(inputPath), tsvPath, debug)
+	markDirBlocks(outputPrefix, opts.config.DirBlocks)
+
+	extraOps := make(map[string]stat.Operation)
+
+	if opts.symlinks {
+		output := createOutputFileWithSuffix(outputPrefix, statSymlinksFileSuffix)
+		defer closeExtraOutputFile(output)
+
+		extraOps["symlink"] = stat.SymlinkOperation(output)
+	}
+
+	if opts.modes {
+		output := createOutputFileWithSuffix(outputPrefix, statModesFileSuffix)
+		defer closeExtraOutputFile(output)
+
+		extraOps["mode"] = stat.ModeOperation(output)
+	}
+
+	if opts.nlinkThreshold > 0 {
+		output := createOutputFileWithSuffix(outputPrefix, statNlinksFileSuffix)
+		defer closeExtraOutputFile(output)
+
+		extraOps["nlink"] = stat.NlinkOperation(output, opts.nlinkThreshold)
+	}
+
+	scanAndStatInput(input, createStatOutputFile(outputPrefix), extraOps, opts)
+}
+
+// markDirBlocks creates an empty marker file named after outputPrefix if
+// dirBlocks is true, or removes any such file left over from a previous run if
+// not.
+func markDirBlocks(outputPrefix string, dirBlocks bool) {
+	marker := outputPrefix + statDirBlocksFileSuffix
+
+	if !dirBlocks {
+		if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+			die("failed to remove dir blocks marker file: %s", err)
+		}
+
+		return
+	}
+
+	f, err := os.Create(marker)
+	if err != nil {
+		die("failed to create dir blocks marker file: %s", err)
+	}
+
+	if err = f.Close(); err != nil {
+		die("failed to close dir blocks marker file: %s", err)
+	}
+}
+
+// openStatInput opens the given input file, or returns STDIN if inputPath is
+// "-".
+func openStatInput(inputPath string) *os.File {
+	if inputPath == statStdinInput {
+		return os.Stdin
+	}
+
+	input, err := os.Open(inputPath)
+	if err != nil {
+		die("failed to open input file: %s", err)
+	}
+
+	return input
+}
+
+// closeExtraOutputFile closes the given file, warning on failure.
+func closeExtraOutputFile(output *os.File) {
+	if err := output.Close(); err != nil {
+		warn("failed to close output file %s: %s", output.Name(), err)
+	}
 }
 
 // createStatOutputFile creates a file named input.stats.
@@ -169,33 +424,47 @@ func createOutputFileWithSuffix(prefixPath, suffix string) *os.File {
 }
 
 // scanAndStatInput scans through the input, stats each path, and outputs the
-// results to the output.
-//
-// If tsvPath is not empty, also does chmod and chown operations on certain
-// paths.
+// results to the output. The given extraOps are also added to the scan, keyed
+// on their operation names.
 //
-// If debug is true, outputs timings for Lstat calls and other operations.
-func scanAndStatInput(input, output *os.File, tsvPath string, debug bool) {
+// The given opts are used as described in statOptions.
+func scanAndStatInput(input, output *os.File, extraOps map[string]stat.Operation, opts statOptions) {
 	var frequency time.Duration
-	if debug {
+	if opts.debug {
 		frequency = reportFrequency
 	}
 
 	statter := stat.WithTimeout(lstatTimeout, lstatAttempts, lstatConsecutiveFails, appLogger)
-	pConfig := stat.PathsConfig{Logger: appLogger, ReportFrequency: frequency, ScanTimeout: scanTimeout}
+	pConfig := stat.PathsConfig{
+		Logger:          appLogger,
+		ReportFrequency: frequency,
+		ScanTimeout:     scanTimeout,
+		FailFast:        opts.failFast,
+		Canonicalise:    opts.canonicalise,
+	}
 	p := stat.NewPaths(statter, pConfig)
 
-	if err := p.AddOperation("file", stat.FileOperation(output)); err != nil {
+	if err := p.AddOperation("file", stat.FileOperationWithConfig(output, opts.config)); err != nil {
 		die("%s", err)
 	}
 
-	if err := addChOperation(tsvPath, p); err != nil {
+	for name, op := range extraOps {
+		if err := p.AddOperation(name, op); err != nil {
+			die("%s", err)
+		}
+	}
+
+	if err := addChOperation(opts.tsvPath, p); err != nil {
 		die("%s", err)
 	}
 
 	if err := p.Scan(input); err != nil {
 		die("%s", err)
 	}
+
+	if skipped := p.Skipped(); skipped > 0 {
+		warn("skipped %d paths that could not be statted", skipped)
+	}
 }
 
 // addChOperation adds the chmod&chown operation to the Paths if the tsv file
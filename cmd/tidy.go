@@ -38,11 +38,10 @@ import (
 // it if it does not already exist.
 const destDirPerms = 0770
 
-const disableDeletion = false
-
 // options for this cmd.
 var tidyDir string
 var tidyDate string
+var tidyNoRemove bool
 
 // tidyCmd represents the tidy command.
 var tidyCmd = &cobra.Command{
@@ -76,6 +75,10 @@ user,group,other read & write permissions as the --final_output directory.
 
 Once all output files have been moved, the "multi unique" directory is deleted.
 
+For debugging purposes, you can supply --no_remove to move the output files
+but keep the "multi unique" directory (and everything else in it) for
+inspection. In that case the '.updated' file is not created or touched.
+
 It is safe to call this multiple times if it was, for example, killed half way
 through; it won't clobber final outputs already moved.`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -117,7 +120,7 @@ through; it won't clobber final outputs already moved.`,
 			DestDirPerms: destDirPerms,
 		}
 
-		err = tidy.Up(disableDeletion)
+		err = tidy.Up(tidyNoRemove)
 		if err != nil {
 			die("could not neaten dir: %s", err)
 		}
@@ -130,4 +133,6 @@ func init() {
 	// flags specific to this sub-command
 	tidyCmd.Flags().StringVarP(&tidyDir, "final_output", "f", "", "final output directory")
 	tidyCmd.Flags().StringVarP(&tidyDate, "date", "d", "", "datestamp of when 'wrstat multi' was called")
+	tidyCmd.Flags().BoolVar(&tidyNoRemove, "no_remove", false,
+		"move the output files but don't delete the working directory")
 }
@@ -26,8 +26,12 @@
 package cmd
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -53,6 +57,17 @@ var (
 	walkNumOfJobs    int
 	walkID           string
 	walkCh           string
+	walkDirBlocks    bool
+	walkSymlinks     bool
+	walkUIDMap       string
+	walkGIDMap       string
+	walkModes        bool
+	walkCanonical    bool
+	walkPruneDirs    []string
+	walkCountOnly    bool
+	walkNlinks       uint64
+	walkDirsOutput   string
+	walkRetries      uint8
 )
 
 // walkCmd represents the walk command.
@@ -76,9 +91,39 @@ to output files in the given output directory. The number of files is such that
 they will each contain about --inodes_per_stat entries (or if --num_stats was
 supplied greater than zero, then there will be that number of output files).
 
+Entries are written in lexical order of their full paths (with directories
+having a trailing '/'), distributed between the output files in a round-robin,
+so each output file is also sorted. This is done as the walk proceeds, without
+needing to hold all the paths in memory, so there is no need to sort the output
+files afterwards.
+
 For each output file, a 'wrstat stat' job is then added to wr's queue with the
-given dependency group. For the meaning of the --ch option which is passed
-through to stat, see 'wrstat stat -h'.
+given dependency group. For the meaning of the --ch, --dir_blocks, --symlinks,
+--modes, --nlink_threshold, --canonicalise, --uid_map and --gid_map options
+which are passed through to stat, see 'wrstat stat -h'. In addition,
+--canonicalise makes this resolve any symlinks in the path of the directory of
+interest (once, before walking), so that all the output paths are canonical.
+
+Once the walk is done, a "walk complete" line is logged to walk.log in the
+output directory, giving the start and end times of the walk, the number of
+entries output, and the number of entries output per second.
+
+If you supply --prune_dirs, directories with any of the given comma separated
+basenames (eg. '.git,node_modules,site-packages') are output, but are not
+descended in to, so their contents are not output or statted. This is useful
+for excluding developer environments containing huge numbers of small files.
+
+If you supply --dirs_output, the paths of just the directories encountered
+(quoted, 1 per line, in the same order as the main output) are also written to
+the given file. This is useful for building a lightweight directory tree index
+separately from the full stat pipeline.
+
+If you just want to know how big the directory of interest is before doing a
+full run, supply --count_only. The walk is then done without writing any output
+files or adding any jobs, and the number of directories (including the
+directory of interest itself) and other entries found are printed to STDOUT as
+JSON, eg. {"dirs":10,"files":200}. --output_directory and --dependency_group
+are not required in this mode. (--prune_dirs is still respected.)
 
 (When jobs are added to wr's queue to get the work done, they are given a
 --rep_grp of wrstat-stat-[id], so you can use
@@ -89,11 +134,27 @@ NB: when this exits, that does not mean stats have been retrieved. You should
 wait until all jobs in the given dependency group have completed (eg. by adding
 your own job that depends on that group, such as a 'wrstat combine' call).`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if walkCountOnly {
+			if len(args) != 1 {
+				die("exactly 1 directory of interest must be supplied")
+			}
+
+			countDirEntries(args[0])
+
+			return
+		}
+
 		desiredDir := checkArgs(outputDir, depGroup, args)
 
+		if walkCanonical {
+			desiredDir = canonicalDir(desiredDir)
+		}
+
 		s, d := newScheduler("", forcedQueue, queuesToAvoid, sudo)
 		defer d()
 
+		s.SetRetries(walkRetries)
+
 		if walkID == "" {
 			walkID = statRepGrp(desiredDir, scheduler.UniqueString())
 		}
@@ -120,7 +181,23 @@ func init() {
 		&depGroup,
 		"dependency_group", "d", "",
 		"dependency group that stat jobs added to wr will belong to")
+	walkCmd.Flags().StringSliceVar(&walkPruneDirs, "prune_dirs", nil,
+		"comma separated basenames of directories not to descend in to")
+	walkCmd.Flags().BoolVar(&walkCountOnly, "count_only", false,
+		"just print the number of entries in the directory of interest")
+	walkCmd.Flags().StringVar(&walkDirsOutput, "dirs_output", "",
+		"also write just the directory paths encountered to this file")
+	walkCmd.Flags().Uint8Var(&walkRetries, "retries", scheduler.DefaultRetries,
+		"number of times to retry failed stat jobs")
 	walkCmd.Flags().StringVar(&walkCh, "ch", "", "passed through to 'wrstat stat'")
+	walkCmd.Flags().BoolVar(&walkDirBlocks, "dir_blocks", false, "passed through to 'wrstat stat'")
+	walkCmd.Flags().BoolVar(&walkSymlinks, "symlinks", false, "passed through to 'wrstat stat'")
+	walkCmd.Flags().BoolVar(&walkModes, "modes", false, "passed through to 'wrstat stat'")
+	walkCmd.Flags().Uint64Var(&walkNlinks, "nlink_threshold", 0, "passed through to 'wrstat stat'")
+	walkCmd.Flags().BoolVar(&walkCanonical, "canonicalise", false,
+		"resolve symlinks in the directory of interest, and pass through to 'wrstat stat'")
+	walkCmd.Flags().StringVar(&walkUIDMap, "uid_map", "", "passed through to 'wrstat stat'")
+	walkCmd.Flags().StringVar(&walkGIDMap, "gid_map", "", "passed through to 'wrstat stat'")
 	walkCmd.Flags().StringVarP(&forcedQueue, "queue", "q", "", "force a particular queue to be used when scheduling jobs")
 	walkCmd.Flags().StringVar(&queuesToAvoid, "queues_avoid", "",
 		"force queues that include a substring from this comma-separated list to be avoided when scheduling jobs")
@@ -143,6 +220,17 @@ func checkArgs(out, dep string, args []string) string {
 	return args[0]
 }
 
+// canonicalDir returns the given directory cleaned and with any symlinks in it
+// resolved.
+func canonicalDir(dir string) string {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		die("failed to resolve directory of interest: %s", err)
+	}
+
+	return resolved
+}
+
 // statRepGrp returns a rep_grp that can be used for the stat jobs walk will
 // create.
 func statRepGrp(dir, unique string) string {
@@ -163,7 +251,11 @@ func walkDirAndScheduleStats(desiredDir, outputDir string, statJobs, inodes int,
 		die("failed to create walk output files: %s", err)
 	}
 
-	walker := walk.New(files.WritePaths(), true, false)
+	cb, closeDirs := withDirsOutput(files.WritePaths(), walkDirsOutput)
+
+	walker := walk.New(cb, true, false)
+	walker.PruneDirs(walkPruneDirs...)
+	start := time.Now()
 
 	defer func() {
 		err = files.Close()
@@ -179,7 +271,99 @@ func walkDirAndScheduleStats(desiredDir, outputDir string, statJobs, inodes int,
 		die("failed to walk the filesystem: %s", err)
 	}
 
-	scheduleStatJobs(files.Paths, depGroup, repGroup, yamlPath, s)
+	closeDirs()
+
+	logWalkSummary(start, time.Now(), files.Entries())
+
+	scheduleStatJobs(files.Paths, depGroup, repGroup, walkStatArgs(yamlPath), s)
+}
+
+// withDirsOutput returns a PathCallback that calls the given one, and if path
+// is not blank, also writes the quoted paths of any directories to a file at
+// path. The returned function must be called after the walk to flush and close
+// that file.
+func withDirsOutput(cb walk.PathCallback, path string) (walk.PathCallback, func()) {
+	if path == "" {
+		return cb, func() {}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		die("failed to create dirs output file: %s", err)
+	}
+
+	w := bufio.NewWriter(f)
+
+	return func(entry *walk.Dirent) error {
+			if err := cb(entry); err != nil {
+				return err
+			}
+
+			if !entry.IsDir() {
+				return nil
+			}
+
+			_, err := w.WriteString(strconv.Quote(string(entry.Bytes())) + "\n")
+
+			return err
+		}, func() {
+			if err := w.Flush(); err != nil {
+				die("failed to write dirs output file: %s", err)
+			}
+
+			if err := f.Close(); err != nil {
+				die("failed to close dirs output file: %s", err)
+			}
+		}
+}
+
+// logWalkSummary logs the start and end times of a walk, along with the number
+// of entries it output and the rate it output them at.
+func logWalkSummary(start, end time.Time, entries int) {
+	var rate float64
+	if elapsed := end.Sub(start).Seconds(); elapsed > 0 {
+		rate = float64(entries) / elapsed
+	}
+
+	appLogger.Info("walk complete", "start", start.Format(time.RFC3339), "end", end.Format(time.RFC3339),
+		"entries", entries, "entries_per_sec", fmt.Sprintf("%.0f", rate))
+}
+
+// walkCounts holds the number of directories and other entries found by a walk.
+type walkCounts struct {
+	Dirs  int64 `json:"dirs"`
+	Files int64 `json:"files"`
+}
+
+// countDirEntries walks the given directory and prints the number of
+// directories and other entries within it as JSON to STDOUT.
+func countDirEntries(desiredDir string) {
+	var counts walkCounts
+
+	walker := walk.New(func(entry *walk.Dirent) error {
+		if entry.IsDir() {
+			counts.Dirs++
+		} else {
+			counts.Files++
+		}
+
+		return nil
+	}, true, false)
+	walker.PruneDirs(walkPruneDirs...)
+
+	err := walker.Walk(desiredDir, func(path string, err error) {
+		warn("error processing %s: %s", path, err)
+	})
+	if err != nil {
+		die("failed to walk the filesystem: %s", err)
+	}
+
+	line, err := json.Marshal(counts)
+	if err != nil {
+		die("failed to encode counts: %s", err)
+	}
+
+	fmt.Println(string(line))
 }
 
 // calculateSplitBasedOnInodes sees how many used inodes are on the given path
@@ -201,17 +385,64 @@ func calculateSplitBasedOnInodes(n int, mount string) int {
 	return jobs
 }
 
-// scheduleStatJobs adds a 'wrstat stat' job to wr's queue for each out path.
-// The jobs are added with the given dep and rep groups, and the given yaml for
-// the --ch arg if not blank.
-func scheduleStatJobs(outPaths []string, depGroup string, repGrp, yamlPath string, s *scheduler.Scheduler) {
-	jobs := make([]*jobqueue.Job, len(outPaths))
+// walkStatArgs returns the args that walk's stat jobs should be given: the
+// given yaml for the --ch arg if not blank, and --dir_blocks, --symlinks,
+// --modes, --nlink_threshold, --canonicalise, --uid_map and --gid_map passed
+// through if set.
+func walkStatArgs(yamlPath string) string {
+	var args string
 
-	cmd := s.Executable() + " stat "
 	if yamlPath != "" {
-		cmd += fmt.Sprintf("--ch %s ", yamlPath)
+		args += fmt.Sprintf("--ch %s ", yamlPath)
 	}
 
+	if walkDirBlocks {
+		args += "--dir_blocks "
+	}
+
+	if walkSymlinks {
+		args += "--symlinks "
+	}
+
+	if walkModes {
+		args += "--modes "
+	}
+
+	if walkNlinks > 0 {
+		args += fmt.Sprintf("--nlink_threshold %d ", walkNlinks)
+	}
+
+	if walkCanonical {
+		args += "--canonicalise "
+	}
+
+	if walkUIDMap != "" {
+		args += fmt.Sprintf("--uid_map %s ", walkUIDMap)
+	}
+
+	if walkGIDMap != "" {
+		args += fmt.Sprintf("--gid_map %s ", walkGIDMap)
+	}
+
+	return args
+}
+
+// scheduleStatJobs adds a 'wrstat stat' job to wr's queue for each out path.
+// The jobs are added with the given dep and rep groups, and the given
+// statArgs.
+func scheduleStatJobs(outPaths []string, depGroup string, repGrp, statArgs string, s *scheduler.Scheduler) {
+	addJobsToQueue(s, newStatJobs(outPaths, depGroup, repGrp, statArgs, s))
+}
+
+// newStatJobs returns a 'wrstat stat' job for each out path, with the given dep
+// and rep groups, and the given statArgs.
+func newStatJobs(outPaths []string, depGroup string, repGrp, statArgs string,
+	s *scheduler.Scheduler,
+) []*jobqueue.Job {
+	jobs := make([]*jobqueue.Job, len(outPaths))
+
+	cmd := s.Executable() + " stat " + statArgs
+
 	req := scheduler.DefaultRequirements()
 	req.Time = statTime
 	req.RAM = statRAM
@@ -222,5 +453,5 @@ func scheduleStatJobs(outPaths []string, depGroup string, repGrp, yamlPath strin
 		jobs[i].LimitGroups = []string{"wrstat-stat"}
 	}
 
-	addJobsToQueue(s, jobs)
+	return jobs
 }
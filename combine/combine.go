@@ -30,10 +30,15 @@ import (
 	"io"
 	"os"
 	"runtime"
+	"sync/atomic"
 
 	"github.com/klauspost/pgzip"
 )
 
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
 const bytesInMB = 1000000
 const pgzipWriterBlocksMultiplier = 2
 
@@ -41,14 +46,35 @@ const pgzipWriterBlocksMultiplier = 2
 // file for its output. It writes to the output the compressed, concatenated
 // inputs.
 func ConcatenateAndCompress(inputs []*os.File, output *os.File, unquoteComparison bool) error {
-	compressor := pgzip.NewWriter(output)
+	return concatenateAndCompress(inputs, output, unquoteComparison, nil, nil)
+}
+
+// concatenateAndCompress is like ConcatenateAndCompress(), but if p is not nil,
+// it is updated as the inputs are merged and the output written, and if ix is
+// not nil, the output is written as a gzip member per top-level entry, with an
+// index of them.
+func concatenateAndCompress(inputs []*os.File, output *os.File, unquoteComparison bool,
+	p *progress, ix *statsIndexer) error {
+	var (
+		w         io.Writer = output
+		exhausted *atomic.Int64
+	)
 
-	err := compressor.SetConcurrency(bytesInMB, runtime.GOMAXPROCS(0)*pgzipWriterBlocksMultiplier)
+	if p != nil {
+		w = &countingWriter{Writer: output, n: &p.bytesWritten}
+		exhausted = &p.filesDone
+	}
+
+	r, err := mergeSortedReaders(inputs, unquoteComparison, exhausted)
 	if err != nil {
 		return err
 	}
 
-	r, err := MergeSortedFiles(inputs, unquoteComparison)
+	if ix != nil {
+		return ix.write(r, w)
+	}
+
+	compressor, err := newCompressor(w)
 	if err != nil {
 		return err
 	}
@@ -59,3 +85,16 @@ func ConcatenateAndCompress(inputs []*os.File, output *os.File, unquoteCompariso
 
 	return compressor.Close()
 }
+
+// newCompressor returns a pgzip writer to w that compresses in parallel.
+func newCompressor(w io.Writer) (*pgzip.Writer, error) {
+	compressor := pgzip.NewWriter(w)
+
+	return compressor, setCompressorConcurrency(compressor)
+}
+
+// setCompressorConcurrency sets our desired concurrency on the given pgzip
+// writer, which is needed again after every Reset().
+func setCompressorConcurrency(compressor *pgzip.Writer) error {
+	return compressor.SetConcurrency(bytesInMB, runtime.GOMAXPROCS(0)*pgzipWriterBlocksMultiplier)
+}
@@ -0,0 +1,242 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// duplicatesSortMemory is the main memory buffer size we let the external sort
+// use before it spills to temporary files.
+const duplicatesSortMemory = "400M"
+
+// the columns of a duplicate candidate record, as written to the temporary
+// file that gets sorted.
+const (
+	dupColName = iota
+	dupColSize
+	dupColPath
+	dupCols
+)
+
+const errBadDuplicateRecord = Error("duplicate candidate record does not have 3 columns")
+
+type duplicate struct {
+	name string
+	size int64
+	path string
+}
+
+// sameAs returns true if we have the same name and size as the given
+// duplicate.
+func (d duplicate) sameAs(other duplicate) bool {
+	return d.name == other.name && d.size == other.size
+}
+
+// DuplicateFilenames reads combined stats output (as produced by StatFiles(),
+// but uncompressed) from r, and writes a report to w of regular file basenames
+// that appear at least minCount times with the same size, where that size is
+// at least minSize.
+//
+// Each line of the report is tab separated: the quoted basename, the size, the
+// number of occurrences, and then the quoted path of each occurrence.
+//
+// Since the input is sorted by path, files of at least minSize are written to a
+// temporary file in tmpDir and re-sorted by basename and size using the
+// external sort command, so memory use does not grow with the number of files.
+func DuplicateFilenames(r io.Reader, w io.Writer, minSize int64, minCount int, tmpDir string) error {
+	candidates, err := os.CreateTemp(tmpDir, ".duplicates.")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(candidates.Name())
+
+	if err = writeDuplicateCandidates(r, candidates, minSize); err != nil {
+		candidates.Close()
+
+		return err
+	}
+
+	if err = candidates.Close(); err != nil {
+		return err
+	}
+
+	return reportSortedDuplicates(candidates.Name(), w, minCount, tmpDir)
+}
+
+// writeDuplicateCandidates parses the stats lines in r and writes the regular
+// files that are at least minSize to w, one per line, as tab separated quoted
+// basename, size and quoted path.
+func writeDuplicateCandidates(r io.Reader, w io.Writer, minSize int64) error {
+	bw := bufio.NewWriter(w)
+
+	err := scanStatsLines(r, func(cols []string) error {
+		if cols[statsColType] != "f" {
+			return nil
+		}
+
+		size, err := strconv.ParseInt(cols[statsColSize], 10, 64)
+		if err != nil || size < minSize {
+			return err
+		}
+
+		path, err := strconv.Unquote(cols[statsColPath])
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(bw, "%q\t%d\t%s\n", filepath.Base(path), size, cols[statsColPath])
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// reportSortedDuplicates sorts the candidates file at path by basename and size
+// using the external sort command, and then streams through the sorted output
+// writing a report line to w for each group of at least minCount.
+func reportSortedDuplicates(path string, w io.Writer, minCount int, tmpDir string) error {
+	cmd := exec.Command("sort", "-t", "\t", "-k1,1", "-k2,2n", "-k3,3", //nolint:gosec
+		"-S", duplicatesSortMemory, "-T", tmpDir, path)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	cmd.Stderr = os.Stderr
+
+	sorted, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	if err = groupSortedDuplicates(sorted, w, minCount); err != nil {
+		cmd.Process.Kill() //nolint:errcheck
+		cmd.Wait()         //nolint:errcheck
+
+		return err
+	}
+
+	return cmd.Wait()
+}
+
+// groupSortedDuplicates reads candidate records sorted by basename and size
+// from r, and writes a report line to w for each group of records sharing a
+// basename and size that has at least minCount members.
+func groupSortedDuplicates(r io.Reader, w io.Writer, minCount int) error {
+	bw := bufio.NewWriter(w)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxStatsLineLength)
+
+	var group []duplicate
+
+	flush := func() error {
+		if len(group) < minCount {
+			return nil
+		}
+
+		return writeDuplicateGroup(bw, group)
+	}
+
+	for scanner.Scan() {
+		d, err := parseDuplicateRecord(scanner.Text())
+		if err != nil {
+			return err
+		}
+
+		if len(group) > 0 && !d.sameAs(group[0]) {
+			if err = flush(); err != nil {
+				return err
+			}
+
+			group = group[:0]
+		}
+
+		group = append(group, d)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// parseDuplicateRecord parses a line written by writeDuplicateCandidates().
+func parseDuplicateRecord(line string) (duplicate, error) {
+	cols := strings.Split(line, "\t")
+	if len(cols) != dupCols {
+		return duplicate{}, errBadDuplicateRecord
+	}
+
+	name, err := strconv.Unquote(cols[dupColName])
+	if err != nil {
+		return duplicate{}, err
+	}
+
+	size, err := strconv.ParseInt(cols[dupColSize], 10, 64)
+	if err != nil {
+		return duplicate{}, err
+	}
+
+	path, err := strconv.Unquote(cols[dupColPath])
+
+	return duplicate{name: name, size: size, path: path}, err
+}
+
+// writeDuplicateGroup writes a single report line for the given group of
+// duplicates, which must all share a name and size.
+func writeDuplicateGroup(w io.Writer, group []duplicate) error {
+	if _, err := fmt.Fprintf(w, "%q\t%d\t%d", group[0].name, group[0].size, len(group)); err != nil {
+		return err
+	}
+
+	for _, d := range group {
+		if _, err := fmt.Fprintf(w, "\t%q", d.path); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
@@ -0,0 +1,102 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDuplicateFilenames(t *testing.T) {
+	Convey("Given sorted stats lines with some planted duplicate filenames", t, func() {
+		input := statsLine("/a/", 4096, "d") +
+			statsLine("/a/b/", 4096, "d") +
+			statsLine("/a/b/copy.bam", 5000, "f") +
+			statsLine("/a/b/small.txt", 10, "f") +
+			statsLine("/a/c/", 4096, "d") +
+			statsLine("/a/c/copy.bam", 5000, "f") +
+			statsLine("/a/c/other.bam", 5000, "f") +
+			statsLine("/a/c/small.txt", 10, "f") +
+			statsLine("/a/d/", 4096, "d") +
+			statsLine("/a/d/copy.bam", 5000, "f") +
+			statsLine("/a/d/other.bam", 6000, "f") +
+			statsLine("/a/d/lin\tk", 5000, "l") +
+			statsLine("/a/e/lin\tk", 5000, "l")
+
+		Convey("you can report names seen enough times with the same size above a threshold", func() {
+			var out strings.Builder
+
+			err := DuplicateFilenames(strings.NewReader(input), &out, 1000, 2, t.TempDir())
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldEqual, `"copy.bam"	5000	3	"/a/b/copy.bam"	"/a/c/copy.bam"	"/a/d/copy.bam"`+"\n")
+
+			out.Reset()
+
+			err = DuplicateFilenames(strings.NewReader(input), &out, 1, 2, t.TempDir())
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldEqual, `"copy.bam"	5000	3	"/a/b/copy.bam"	"/a/c/copy.bam"	"/a/d/copy.bam"`+"\n"+
+				`"small.txt"	10	2	"/a/b/small.txt"	"/a/c/small.txt"`+"\n")
+
+			out.Reset()
+
+			err = DuplicateFilenames(strings.NewReader(input), &out, 1000, 4, t.TempDir())
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldBeEmpty)
+		})
+
+		Convey("candidates are sorted on disk, and the temporary files are removed afterwards", func() {
+			var out strings.Builder
+
+			tmpDir := t.TempDir()
+
+			err := DuplicateFilenames(strings.NewReader(input), &out, 1, 2, tmpDir)
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldNotBeEmpty)
+
+			entries, err := os.ReadDir(tmpDir)
+			So(err, ShouldBeNil)
+			So(entries, ShouldBeEmpty)
+		})
+
+		Convey("malformed input returns an error", func() {
+			var out strings.Builder
+
+			err := DuplicateFilenames(strings.NewReader("\"/a\"\t1\n"), &out, 0, 2, t.TempDir())
+			So(err, ShouldEqual, errBadStatsLine)
+		})
+	})
+}
+
+// statsLine returns a 'wrstat stat' style line for the given path, size and
+// type.
+func statsLine(path string, size int64, typ string) string {
+	return fmt.Sprintf("%s\t%d\t1\t2\t3\t4\t5\t%s\t6\t1\t7\n", strconv.Quote(path), size, typ)
+}
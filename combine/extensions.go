@@ -0,0 +1,163 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxTrackedExtensions is the most distinct extensions ExtensionTally() will
+// hold counts for; files with extensions seen after this many have been
+// tracked are counted as "other".
+const maxTrackedExtensions = 100000
+
+type extensionTally struct {
+	ext   string
+	count int64
+	bytes int64
+}
+
+func (e *extensionTally) add(other extensionTally) {
+	e.count += other.count
+	e.bytes += other.bytes
+}
+
+// compareExtensionTallies orders by descending bytes, then descending count,
+// then extension.
+func compareExtensionTallies(a, b extensionTally) int {
+	if c := cmp.Compare(b.bytes, a.bytes); c != 0 {
+		return c
+	}
+
+	if c := cmp.Compare(b.count, a.count); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a.ext, b.ext)
+}
+
+// ExtensionTally reads combined stats output (as produced by StatFiles(), but
+// uncompressed) from r, and writes a report to w of the number and total size
+// of regular files per lowercased file extension.
+//
+// Each line of the report is tab separated: the quoted extension (without the
+// leading dot; blank for files with no extension), the number of files and
+// their total size in bytes. Lines are sorted by descending size, and only the
+// topK extensions are reported individually; the remaining extensions are
+// summed in to a final line with an unquoted extension of "other".
+//
+// To bound memory usage, only the first 100,000 distinct extensions seen are
+// tracked; files with any other extension are always counted as "other".
+func ExtensionTally(r io.Reader, w io.Writer, topK int) error {
+	tallies, other, err := tallyExtensions(r)
+	if err != nil {
+		return err
+	}
+
+	slices.SortFunc(tallies, compareExtensionTallies)
+
+	if len(tallies) > topK {
+		for _, t := range tallies[topK:] {
+			other.add(t)
+		}
+
+		tallies = tallies[:topK]
+	}
+
+	bw := bufio.NewWriter(w)
+
+	for _, t := range tallies {
+		if _, err := fmt.Fprintf(bw, "%q\t%d\t%d\n", t.ext, t.count, t.bytes); err != nil {
+			return err
+		}
+	}
+
+	if other.count > 0 {
+		if _, err := fmt.Fprintf(bw, "other\t%d\t%d\n", other.count, other.bytes); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// tallyExtensions parses the stats lines in r and returns the tallies of
+// regular files per extension, along with the tally of files whose extensions
+// weren't tracked.
+func tallyExtensions(r io.Reader) ([]extensionTally, extensionTally, error) {
+	tallies := make(map[string]*extensionTally)
+
+	var other extensionTally
+
+	err := scanStatsLines(r, func(cols []string) error {
+		if cols[statsColType] != "f" {
+			return nil
+		}
+
+		size, err := strconv.ParseInt(cols[statsColSize], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		path, err := strconv.Unquote(cols[statsColPath])
+		if err != nil {
+			return err
+		}
+
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+
+		t, ok := tallies[ext]
+		if !ok {
+			if len(tallies) >= maxTrackedExtensions {
+				other.add(extensionTally{count: 1, bytes: size})
+
+				return nil
+			}
+
+			t = &extensionTally{ext: ext}
+			tallies[ext] = t
+		}
+
+		t.add(extensionTally{count: 1, bytes: size})
+
+		return nil
+	})
+
+	list := make([]extensionTally, 0, len(tallies))
+
+	for _, t := range tallies {
+		list = append(list, *t)
+	}
+
+	return list, other, err
+}
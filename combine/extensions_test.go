@@ -0,0 +1,76 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExtensionTally(t *testing.T) {
+	Convey("Given stats lines for files with various extensions", t, func() {
+		input := statsLine("/a/", 4096, "d") +
+			statsLine("/a/1.BAM", 100, "f") +
+			statsLine("/a/2.bam", 50, "f") +
+			statsLine("/a/3.cram", 120, "f") +
+			statsLine("/a/4.txt", 5, "f") +
+			statsLine("/a/5.tar.gz", 7, "f") +
+			statsLine("/a/README", 3, "f") +
+			statsLine("/a/link.bam", 1000, "l") +
+			statsLine("/a/dir.bam/", 4096, "d")
+
+		Convey("you can tally them by lowercased extension", func() {
+			var out strings.Builder
+
+			err := ExtensionTally(strings.NewReader(input), &out, 10)
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldEqual, `"bam"	2	150`+"\n"+
+				`"cram"	1	120`+"\n"+
+				`"gz"	1	7`+"\n"+
+				`"txt"	1	5`+"\n"+
+				`""	1	3`+"\n")
+		})
+
+		Convey("extensions beyond the top K are rolled up", func() {
+			var out strings.Builder
+
+			err := ExtensionTally(strings.NewReader(input), &out, 2)
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldEqual, `"bam"	2	150`+"\n"+
+				`"cram"	1	120`+"\n"+
+				"other\t3\t15\n")
+		})
+
+		Convey("malformed input returns an error", func() {
+			var out strings.Builder
+
+			err := ExtensionTally(strings.NewReader("\"/a\"\t1\n"), &out, 2)
+			So(err, ShouldEqual, errBadStatsLine)
+		})
+	})
+}
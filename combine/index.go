@@ -0,0 +1,242 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/pgzip"
+)
+
+const errNotIndexed = Error("path not found in index")
+
+// indexCols is the number of columns in a StatFilesWithIndex() index line.
+const indexCols = 3
+
+// StatFilesWithIndex is like StatFiles(), but also writes an index of the
+// output to index.
+//
+// The output is written as a series of concatenated gzip members, with a new
+// member started for each top-level entry: the first path component beneath
+// root, which should be the directory that was walked. This is still a valid
+// gzip file that decompresses to the same content as StatFiles() would write.
+// An error is returned if a path in the inputs is not within root.
+//
+// Each line of the index is tab separated: the quoted path of a top-level entry
+// (the walked directory itself with a trailing slash, or the path of the entry
+// directly beneath it, which for a directory has a trailing slash), the byte
+// offset of its gzip member in output, and the length of that member. You can
+// pass the index to StatsForPath() to read just the entries for one top-level
+// entry.
+func StatFilesWithIndex(inputs []*os.File, output *os.File, index io.Writer, root string) error {
+	return concatenateAndCompress(inputs, output, true, nil, newStatsIndexer(index, root))
+}
+
+// statsIndexer is used by StatFilesWithIndex() to track the current gzip
+// member.
+type statsIndexer struct {
+	ow     *offsetWriter
+	index  *bufio.Writer
+	zw     *pgzip.Writer
+	root   string
+	top    string
+	first  string
+	offset int64
+}
+
+func newStatsIndexer(index io.Writer, root string) *statsIndexer {
+	return &statsIndexer{
+		index: bufio.NewWriter(index),
+		root:  rootDir(root),
+	}
+}
+
+// write compresses the stats lines read from r to w, with a gzip member per
+// top-level entry, and writes the index.
+func (ix *statsIndexer) write(r io.Reader, w io.Writer) error {
+	ix.ow = &offsetWriter{Writer: w}
+
+	err := scanStatsLines(r, func(cols []string) error {
+		return ix.add(cols)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = ix.endMember(); err != nil {
+		return err
+	}
+
+	return ix.index.Flush()
+}
+
+// add writes the given stats line columns to the current gzip member, starting
+// a new member first if the line is for a different top-level entry.
+func (ix *statsIndexer) add(cols []string) error {
+	path, err := strconv.Unquote(cols[statsColPath])
+	if err != nil {
+		return err
+	}
+
+	if err = checkWithinRoot(path, ix.root); err != nil {
+		return err
+	}
+
+	if top := topLevelName(path, ix.root); ix.zw == nil || top != ix.top {
+		if err = ix.endMember(); err != nil {
+			return err
+		}
+
+		ix.top = top
+
+		if err = ix.startMember(path); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(ix.zw, strings.Join(cols, "\t")+"\n")
+
+	return err
+}
+
+// startMember starts a new gzip member for the current top-level entry, which
+// the given path is the first seen for.
+func (ix *statsIndexer) startMember(path string) error {
+	ix.first = ix.topLevelPath(path)
+	ix.offset = ix.ow.n
+
+	if ix.zw == nil {
+		var err error
+
+		ix.zw, err = newCompressor(ix.ow)
+
+		return err
+	}
+
+	ix.zw.Reset(ix.ow)
+
+	return setCompressorConcurrency(ix.zw)
+}
+
+// topLevelPath returns the path of the current top-level entry, given a path
+// within it. This works even if the entry for the top-level directory itself
+// is missing from our input.
+func (ix *statsIndexer) topLevelPath(path string) string {
+	if ix.top == "" {
+		return ix.root
+	}
+
+	top := ix.root + ix.top
+	if path != top {
+		top += "/"
+	}
+
+	return top
+}
+
+// endMember closes the current gzip member, if any, and writes its details to
+// the index.
+func (ix *statsIndexer) endMember() error {
+	if ix.zw == nil {
+		return nil
+	}
+
+	if err := ix.zw.Close(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(ix.index, "%q\t%d\t%d\n", ix.first, ix.offset, ix.ow.n-ix.offset)
+
+	return err
+}
+
+// offsetWriter is an io.Writer that keeps track of the offset reached in the
+// underlying writer. It must only be read after the pgzip writer writing to it
+// has been closed.
+type offsetWriter struct {
+	io.Writer
+	n int64
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.Writer.Write(p)
+	o.n += int64(n)
+
+	return n, err
+}
+
+// StatsForPath uses the given index, as written by StatFilesWithIndex(), to
+// find the gzip member for the given top-level entry path in the compressed
+// stats written by StatFilesWithIndex(), and returns a reader of just that
+// member's decompressed stats lines.
+//
+// The path must exactly match a path in the index, so for a directory must
+// include its trailing slash.
+func StatsForPath(stats io.ReaderAt, index io.Reader, path string) (io.Reader, error) {
+	offset, length, err := findInIndex(index, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return gzip.NewReader(io.NewSectionReader(stats, offset, length))
+}
+
+// findInIndex returns the offset and length recorded for the given path in the
+// given index.
+func findInIndex(index io.Reader, path string) (int64, int64, error) {
+	scanner := bufio.NewScanner(index)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxStatsLineLength)
+
+	quoted := strconv.Quote(path)
+
+	for scanner.Scan() {
+		cols := strings.Split(scanner.Text(), "\t")
+		if len(cols) != indexCols || cols[0] != quoted {
+			continue
+		}
+
+		offset, err := strconv.ParseInt(cols[1], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		length, err := strconv.ParseInt(cols[2], 10, 64)
+
+		return offset, length, err
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	return 0, 0, errNotIndexed
+}
@@ -0,0 +1,161 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStatFilesWithIndex(t *testing.T) {
+	Convey("Given stats files of sorted stats lines", t, func() {
+		a := statsLine("/x/a/", 4096, "d") + statsLine("/x/a/1", 1, "f") + statsLine("/x/a/2", 2, "f")
+		b := statsLine("/x/b/", 4096, "d") + statsLine("/x/b/c/", 4096, "d") + statsLine("/x/b/c/3", 3, "f")
+		input := statsLine("/x/", 4096, "d") + a + statsLine("/x/a0", 5, "f") + b
+
+		Convey("you can combine and compress them with an index", func() {
+			out, index, err := indexStatsFiles(t, "/x",
+				statsLine("/x/", 4096, "d")+a, statsLine("/x/a0", 5, "f")+b)
+			So(err, ShouldBeNil)
+
+			lines := strings.Split(strings.TrimSuffix(index, "\n"), "\n")
+			So(len(lines), ShouldEqual, 4)
+			So(lines[0], ShouldStartWith, `"/x/"`+"\t0\t")
+			So(lines[1], ShouldStartWith, `"/x/a/"`+"\t")
+			So(lines[2], ShouldStartWith, `"/x/a0"`+"\t")
+			So(lines[3], ShouldStartWith, `"/x/b/"`+"\t")
+
+			Convey("which decompresses to the merged input", func() {
+				r, err := gzip.NewReader(bytes.NewReader(out))
+				So(err, ShouldBeNil)
+
+				data, err := io.ReadAll(r)
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, input)
+			})
+
+			Convey("and lets you read just the entries for a top-level entry", func() {
+				stats := bytes.NewReader(out)
+
+				for path, expected := range map[string]string{"/x/a/": a, "/x/b/": b} {
+					r, err := StatsForPath(stats, strings.NewReader(index), path)
+					So(err, ShouldBeNil)
+
+					data, err := io.ReadAll(r)
+					So(err, ShouldBeNil)
+					So(string(data), ShouldEqual, expected)
+				}
+
+				_, err = StatsForPath(stats, strings.NewReader(index), "/x/a")
+				So(err, ShouldEqual, errNotIndexed)
+			})
+		})
+
+		Convey("malformed input returns an error", func() {
+			_, _, err := indexStatsFiles(t, "/", "\"/a\"\t1\n")
+			So(err, ShouldEqual, errBadStatsLine)
+		})
+
+		Convey("paths outside the walked directory return an error", func() {
+			_, _, err := indexStatsFiles(t, "/y", input)
+			So(err, ShouldEqual, errNotUnderRoot)
+		})
+
+		Convey("the walked directory's own line can be missing", func() {
+			children := statsLine("/x/a/1", 1, "f") + statsLine("/x/a/2", 2, "f")
+
+			out, index, err := indexStatsFiles(t, "/x/", children+b)
+			So(err, ShouldBeNil)
+
+			lines := strings.Split(strings.TrimSuffix(index, "\n"), "\n")
+			So(len(lines), ShouldEqual, 2)
+			So(lines[0], ShouldStartWith, `"/x/a/"`+"\t0\t")
+			So(lines[1], ShouldStartWith, `"/x/b/"`+"\t")
+
+			r, err := StatsForPath(bytes.NewReader(out), strings.NewReader(index), "/x/a/")
+			So(err, ShouldBeNil)
+
+			data, err := io.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, children)
+		})
+	})
+}
+
+// indexStatsFiles writes each of the given contents to a stats file, runs
+// StatFilesWithIndex() on them with the given root, and returns the compressed
+// output, the index and the error.
+func indexStatsFiles(t *testing.T, root string, contents ...string) ([]byte, string, error) {
+	t.Helper()
+
+	dir := t.TempDir()
+	inputs := make([]*os.File, len(contents))
+
+	for i, content := range contents {
+		path := filepath.Join(dir, strconv.Itoa(i)+".stats")
+
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer f.Close()
+
+		inputs[i] = f
+	}
+
+	output, err := os.Create(filepath.Join(dir, "combine.stats.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer output.Close()
+
+	var index bytes.Buffer
+
+	if err = StatFilesWithIndex(inputs, output, &index, root); err != nil {
+		return nil, "", err
+	}
+
+	out, err := os.ReadFile(output.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return out, index.String(), nil
+}
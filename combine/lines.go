@@ -0,0 +1,82 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// the columns of a 'wrstat stat' output line.
+const (
+	statsColPath = iota
+	statsColSize
+	statsColUID
+	statsColGID
+	statsColAtime
+	statsColMtime
+	statsColCtime
+	statsColType
+	statsColInode
+	statsColNlink
+	statsColDev
+	statsCols
+)
+
+// maxStatsLineLength is the longest stats line we will read; paths can be up to
+// 4096 bytes, which could be quoted to 4x that length, leaving plenty of room
+// for the other columns.
+const maxStatsLineLength = 64 * 1024
+
+const errBadStatsLine = Error("stats line has too few columns")
+
+// scanStatsLines calls cb with the tab-separated columns of each line in r,
+// which should be 'wrstat stat' output. Scanning stops at the first error.
+func scanStatsLines(r io.Reader, cb func(cols []string) error) error {
+	return scanColumnLines(r, statsCols, errBadStatsLine, cb)
+}
+
+// scanColumnLines calls cb with the tab-separated columns of each line in r,
+// returning errTooFew if a line has fewer than minCols columns. Scanning stops
+// at the first error.
+func scanColumnLines(r io.Reader, minCols int, errTooFew error, cb func(cols []string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxStatsLineLength)
+
+	for scanner.Scan() {
+		cols := strings.Split(scanner.Text(), "\t")
+		if len(cols) < minCols {
+			return errTooFew
+		}
+
+		if err := cb(cols); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
@@ -33,6 +33,7 @@ import (
 	"io"
 	"os"
 	"slices"
+	"sync/atomic"
 	"unicode/utf8"
 )
 
@@ -48,6 +49,7 @@ type readerHeap struct {
 	heap              []fileLine
 	line              []byte
 	unquoteComparison bool
+	exhausted         *atomic.Int64
 }
 
 func (rh *readerHeap) Len() int {
@@ -218,6 +220,8 @@ func (rh *readerHeap) pushToHeap(index int) error {
 	line, err := rh.readers[index].ReadBytes('\n')
 	if err != nil {
 		if !errors.Is(err, io.EOF) || len(line) == 0 {
+			rh.countExhausted(err)
+
 			return err
 		}
 	}
@@ -234,12 +238,28 @@ func (rh *readerHeap) pushToHeap(index int) error {
 	return nil
 }
 
+// countExhausted increments our exhausted count, if we have one, if the given
+// error is EOF.
+func (rh *readerHeap) countExhausted(err error) {
+	if rh.exhausted != nil && errors.Is(err, io.EOF) {
+		rh.exhausted.Add(1)
+	}
+}
+
 // MergeSortedFiles merges pre-sorted files together.
 func MergeSortedFiles(inputs []*os.File, unquoteComparison bool) (io.Reader, error) {
+	return mergeSortedReaders(inputs, unquoteComparison, nil)
+}
+
+// mergeSortedReaders is like MergeSortedFiles(), but works on any kind of
+// reader, and if exhausted is not nil, it is incremented each time an input has
+// been fully read.
+func mergeSortedReaders[R io.Reader](inputs []R, unquoteComparison bool, exhausted *atomic.Int64) (io.Reader, error) {
 	rh := readerHeap{
 		readers:           make([]bufio.Reader, len(inputs)),
 		heap:              make([]fileLine, 0, len(inputs)),
 		unquoteComparison: unquoteComparison,
+		exhausted:         exhausted,
 	}
 
 	for i, file := range inputs {
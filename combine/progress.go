@@ -0,0 +1,112 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// ProgressFunc receives the number of input files that have been fully merged,
+// the total number of input files, and the number of compressed bytes written
+// to the output so far.
+type ProgressFunc func(filesDone, filesTotal int, bytesWritten int64)
+
+// progress tracks how far through a concatenateAndCompress() we are.
+type progress struct {
+	filesDone    atomic.Int64
+	bytesWritten atomic.Int64
+}
+
+// reportEvery calls cb with our progress every frequency, until the returned
+// function is called, which calls cb one final time.
+func (p *progress) reportEvery(frequency time.Duration, filesTotal int, cb ProgressFunc) func() {
+	report := func() {
+		cb(int(p.filesDone.Load()), filesTotal, p.bytesWritten.Load())
+	}
+
+	ticker := time.NewTicker(frequency)
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+
+	go func() {
+		defer close(doneCh)
+
+		for {
+			select {
+			case <-ticker.C:
+				report()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(stopCh)
+		<-doneCh
+		report()
+	}
+}
+
+// countingWriter is an io.Writer that counts the bytes written through it.
+type countingWriter struct {
+	io.Writer
+	n *atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.Writer.Write(p)
+	c.n.Add(int64(n))
+
+	return n, err
+}
+
+// StatFilesWithProgress is like StatFiles(), but also calls cb every frequency
+// with the progress of the merge, and once more at the end.
+func StatFilesWithProgress(inputs []*os.File, output *os.File, frequency time.Duration, cb ProgressFunc) error {
+	return statFilesWithProgress(inputs, output, nil, frequency, cb)
+}
+
+// StatFilesWithIndexAndProgress is like StatFilesWithIndex(), but also reports
+// progress like StatFilesWithProgress().
+func StatFilesWithIndexAndProgress(inputs []*os.File, output *os.File, index io.Writer, root string,
+	frequency time.Duration, cb ProgressFunc) error {
+	return statFilesWithProgress(inputs, output, newStatsIndexer(index, root), frequency, cb)
+}
+
+func statFilesWithProgress(inputs []*os.File, output *os.File, ix *statsIndexer,
+	frequency time.Duration, cb ProgressFunc) error {
+	p := new(progress)
+	stop := p.reportEvery(frequency, len(inputs), cb)
+
+	defer stop()
+
+	return concatenateAndCompress(inputs, output, true, p, ix)
+}
@@ -0,0 +1,215 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"container/list"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const errNotUnderRoot = Error("stats path is not within the walked directory")
+
+// ShardStats reads combined stats output (as produced by StatFiles(), but
+// uncompressed) from r, and writes each line to one of the given outputs.
+//
+// The output is picked by a hash of the line's top-level directory: the first
+// path component beneath root, which should be the directory that was walked.
+// This means that all the entries for a given top-level directory end up in the
+// same output, and each output remains sorted. Entries for the walked directory
+// itself go to the first output. An error is returned if a path in r is not
+// within root.
+func ShardStats(r io.Reader, outputs []io.Writer, root string) error {
+	root = rootDir(root)
+
+	return scanStatsLines(r, func(cols []string) error {
+		path, err := strconv.Unquote(cols[statsColPath])
+		if err != nil {
+			return err
+		}
+
+		if err = checkWithinRoot(path, root); err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(outputs[shardIndex(path, root, len(outputs))], strings.Join(cols, "\t")+"\n")
+
+		return err
+	})
+}
+
+// SplitStatsByUID reads combined stats output (as produced by StatFiles(), but
+// uncompressed) from r, and writes each line to the writer returned by
+// outputFor() for that line's UID. Each output remains sorted.
+//
+// At most maxOpen outputs are kept open at once: when another is needed, the
+// least recently used one is closed. outputFor() is called with appending
+// false the first time a UID is seen, and with appending true if that UID's
+// output was closed and is needed again, in which case it should return a
+// writer that appends to what was previously written. All outputs are closed
+// before returning.
+func SplitStatsByUID(r io.Reader, maxOpen int,
+	outputFor func(uid string, appending bool) (io.WriteCloser, error),
+) error {
+	outputs := newUIDOutputs(maxOpen, outputFor)
+
+	err := scanStatsLines(r, func(cols []string) error {
+		output, err := outputs.get(cols[statsColUID])
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(output, strings.Join(cols, "\t")+"\n")
+
+		return err
+	})
+
+	if errc := outputs.closeAll(); err == nil {
+		err = errc
+	}
+
+	return err
+}
+
+// uidOutput is an open output for a UID, as held by uidOutputs.
+type uidOutput struct {
+	uid string
+	w   io.WriteCloser
+}
+
+// uidOutputs is a least-recently-used cache of open per-UID outputs.
+type uidOutputs struct {
+	maxOpen   int
+	outputFor func(uid string, appending bool) (io.WriteCloser, error)
+	lru       *list.List
+	open      map[string]*list.Element
+	seen      map[string]bool
+}
+
+func newUIDOutputs(maxOpen int, outputFor func(string, bool) (io.WriteCloser, error)) *uidOutputs {
+	return &uidOutputs{
+		maxOpen:   max(maxOpen, 1),
+		outputFor: outputFor,
+		lru:       list.New(),
+		open:      make(map[string]*list.Element),
+		seen:      make(map[string]bool),
+	}
+}
+
+// get returns the open output for the given UID, opening it (and closing the
+// least recently used output if we're at our limit) if necessary.
+func (u *uidOutputs) get(uid string) (io.Writer, error) {
+	if e, ok := u.open[uid]; ok {
+		u.lru.MoveToFront(e)
+
+		return e.Value.(*uidOutput).w, nil //nolint:forcetypeassert
+	}
+
+	if u.lru.Len() >= u.maxOpen {
+		if err := u.closeOldest(); err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := u.outputFor(uid, u.seen[uid])
+	if err != nil {
+		return nil, err
+	}
+
+	u.seen[uid] = true
+	u.open[uid] = u.lru.PushFront(&uidOutput{uid: uid, w: w})
+
+	return w, nil
+}
+
+// closeOldest closes and forgets the least recently used output.
+func (u *uidOutputs) closeOldest() error {
+	e := u.lru.Back()
+	o := e.Value.(*uidOutput) //nolint:forcetypeassert
+
+	u.lru.Remove(e)
+	delete(u.open, o.uid)
+
+	return o.w.Close()
+}
+
+// closeAll closes all open outputs, returning the first error encountered.
+func (u *uidOutputs) closeAll() error {
+	var firstErr error
+
+	for u.lru.Len() > 0 {
+		if err := u.closeOldest(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// rootDir returns the given walked directory with a single trailing slash, as
+// it appears in stats output.
+func rootDir(root string) string {
+	return strings.TrimSuffix(root, "/") + "/"
+}
+
+// checkWithinRoot returns an error if the given path is not root (as returned
+// by rootDir()) or within it.
+func checkWithinRoot(path, root string) error {
+	if path == root || strings.HasPrefix(path, root) {
+		return nil
+	}
+
+	return errNotUnderRoot
+}
+
+// shardIndex returns which of n shards the given path belongs to, based on a
+// hash of its first path component beneath root.
+func shardIndex(path, root string, n int) int {
+	top := topLevelName(path, root)
+	if top == "" {
+		return 0
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(top))
+
+	return int(h.Sum32() % uint32(n)) //nolint:gosec
+}
+
+// topLevelName returns the first path component of the given path beneath
+// root, or blank if path is root or not within it.
+func topLevelName(path, root string) string {
+	rel, found := strings.CutPrefix(path, root)
+	if !found {
+		return ""
+	}
+
+	top, _, _ := strings.Cut(rel, "/")
+
+	return top
+}
@@ -0,0 +1,242 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestShardStats(t *testing.T) {
+	Convey("Given sorted stats lines for a walked directory", t, func() {
+		var input string
+
+		for _, path := range []string{
+			"/walked/", "/walked/a/", "/walked/a/1.txt", "/walked/a/b/", "/walked/a/b/2.txt",
+			"/walked/c/", "/walked/c/3.txt", "/walked/d.txt", "/walked/e/", "/walked/e/4.txt",
+			"/walked/f/", "/walked/g/", "/walked/g/5.txt",
+		} {
+			input += statsLine(path, 1, "f")
+		}
+
+		Convey("you can shard them by top-level directory", func() {
+			shards := make([]strings.Builder, 3)
+			outputs := make([]io.Writer, len(shards))
+
+			for i := range shards {
+				outputs[i] = &shards[i]
+			}
+
+			err := ShardStats(strings.NewReader(input), outputs, "/walked")
+			So(err, ShouldBeNil)
+
+			seen := make(map[string]int)
+			union := 0
+
+			for i := range shards {
+				lines := strings.Split(strings.TrimSuffix(shards[i].String(), "\n"), "\n")
+				So(lines, ShouldNotBeEmpty)
+
+				union += len(lines)
+
+				for _, line := range lines {
+					So(input, ShouldContainSubstring, line+"\n")
+
+					path, errp := strconv.Unquote(strings.Split(line, "\t")[statsColPath])
+					So(errp, ShouldBeNil)
+
+					top, _, _ := strings.Cut(strings.TrimPrefix(path, "/walked/"), "/")
+					if shard, ok := seen[top]; ok {
+						So(shard, ShouldEqual, i)
+					}
+
+					seen[top] = i
+				}
+			}
+
+			So(union, ShouldEqual, strings.Count(input, "\n"))
+			So(shards[0].String(), ShouldStartWith, statsLine("/walked/", 1, "f"))
+		})
+
+		Convey("a single shard gets everything", func() {
+			var out strings.Builder
+
+			err := ShardStats(strings.NewReader(input), []io.Writer{&out}, "/walked/")
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldEqual, input)
+		})
+
+		Convey("malformed input returns an error", func() {
+			var out strings.Builder
+
+			err := ShardStats(strings.NewReader("\"/a\"\n"), []io.Writer{&out}, "/")
+			So(err, ShouldEqual, errBadStatsLine)
+		})
+
+		Convey("paths outside the walked directory return an error", func() {
+			var out strings.Builder
+
+			err := ShardStats(strings.NewReader(input), []io.Writer{&out}, "/other")
+			So(err, ShouldEqual, errNotUnderRoot)
+		})
+	})
+
+	Convey("Given sorted stats lines missing the line for the walked directory", t, func() {
+		var input string
+
+		for _, path := range []string{"/x/a/", "/x/a/1", "/x/a/2", "/x/b/", "/x/b/3", "/x/c/", "/x/c/4"} {
+			input += statsLine(path, 1, "f")
+		}
+
+		Convey("whole subtrees still end up in the same shard", func() {
+			shards := make([]strings.Builder, 5)
+			outputs := make([]io.Writer, len(shards))
+
+			for i := range shards {
+				outputs[i] = &shards[i]
+			}
+
+			err := ShardStats(strings.NewReader(input), outputs, "/x")
+			So(err, ShouldBeNil)
+
+			shardOf := make(map[string]int)
+			union := 0
+
+			for i := range shards {
+				for _, line := range strings.SplitAfter(shards[i].String(), "\n") {
+					if line == "" {
+						continue
+					}
+
+					union++
+
+					path, errp := strconv.Unquote(strings.Split(line, "\t")[statsColPath])
+					So(errp, ShouldBeNil)
+
+					top := topLevelName(path, "/x/")
+					if shard, ok := shardOf[top]; ok {
+						So(shard, ShouldEqual, i)
+					}
+
+					shardOf[top] = i
+				}
+			}
+
+			So(union, ShouldEqual, 7)
+			So(len(shardOf), ShouldEqual, 3)
+		})
+	})
+}
+
+func TestSplitStatsByUID(t *testing.T) {
+	Convey("Given sorted stats lines with various UIDs", t, func() {
+		lines := []string{
+			"\"/a/\"\t4096\t1\t5\t3\t4\t5\td\t6\t1\t7\n",
+			"\"/a/1\"\t10\t2\t5\t3\t4\t5\tf\t8\t1\t7\n",
+			"\"/a/2\"\t20\t1\t5\t3\t4\t5\tf\t9\t1\t7\n",
+			"\"/a/3\"\t30\t3\t5\t3\t4\t5\tf\t10\t1\t7\n",
+			"\"/a/4\"\t40\t2\t5\t3\t4\t5\tf\t11\t1\t7\n",
+		}
+		input := strings.Join(lines, "")
+
+		Convey("you can split them in to per-UID outputs", func() {
+			outputs := make(map[string]*strings.Builder)
+
+			err := SplitStatsByUID(strings.NewReader(input), 10, func(uid string, appending bool) (io.WriteCloser, error) {
+				So(outputs, ShouldNotContainKey, uid)
+				So(appending, ShouldBeFalse)
+
+				outputs[uid] = &strings.Builder{}
+
+				return nopCloser{outputs[uid]}, nil
+			})
+			So(err, ShouldBeNil)
+			So(len(outputs), ShouldEqual, 3)
+			So(outputs["1"].String(), ShouldEqual, lines[0]+lines[2])
+			So(outputs["2"].String(), ShouldEqual, lines[1]+lines[4])
+			So(outputs["3"].String(), ShouldEqual, lines[3])
+		})
+
+		Convey("with more UIDs than can be open at once, outputs are closed and reopened for appending", func() {
+			outputs := make(map[string]*strings.Builder)
+			open, maxOpen, reopened := 0, 0, 0
+
+			err := SplitStatsByUID(strings.NewReader(input), 2, func(uid string, appending bool) (io.WriteCloser, error) {
+				if appending {
+					So(outputs, ShouldContainKey, uid)
+
+					reopened++
+				} else {
+					So(outputs, ShouldNotContainKey, uid)
+
+					outputs[uid] = &strings.Builder{}
+				}
+
+				open++
+				maxOpen = max(maxOpen, open)
+
+				return closeFunc{outputs[uid], func() { open-- }}, nil
+			})
+			So(err, ShouldBeNil)
+			So(maxOpen, ShouldEqual, 2)
+			So(open, ShouldEqual, 0)
+			So(reopened, ShouldEqual, 1)
+			So(outputs["1"].String(), ShouldEqual, lines[0]+lines[2])
+			So(outputs["2"].String(), ShouldEqual, lines[1]+lines[4])
+			So(outputs["3"].String(), ShouldEqual, lines[3])
+		})
+
+		Convey("errors from creating outputs are returned", func() {
+			err := SplitStatsByUID(strings.NewReader(input), 10, func(string, bool) (io.WriteCloser, error) {
+				return nil, errBadStatsLine
+			})
+			So(err, ShouldEqual, errBadStatsLine)
+		})
+	})
+}
+
+// nopCloser is an io.WriteCloser whose Close does nothing.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// closeFunc is an io.WriteCloser whose Close calls a function.
+type closeFunc struct {
+	io.Writer
+	fn func()
+}
+
+func (c closeFunc) Close() error {
+	c.fn()
+
+	return nil
+}
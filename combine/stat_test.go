@@ -32,6 +32,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/wtsi-ssg/wrstat/v6/fs"
@@ -62,6 +63,20 @@ func TestStatFiles(t *testing.T) {
 				So(actualContent, ShouldEqual, expectedOutput)
 			})
 		})
+
+		Convey("You can concatenate and compress while reporting progress", func() {
+			var reports [][3]int64
+
+			err := StatFilesWithProgress(inputs, output, time.Hour, func(filesDone, filesTotal int, bytesWritten int64) {
+				reports = append(reports, [3]int64{int64(filesDone), int64(filesTotal), bytesWritten})
+			})
+			So(err, ShouldBeNil)
+
+			info, err := os.Stat(outputPath)
+			So(err, ShouldBeNil)
+
+			So(reports, ShouldResemble, [][3]int64{{3, 3, info.Size()}})
+		})
 	})
 }
 
@@ -0,0 +1,65 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"io"
+	"strconv"
+)
+
+// Summary holds overall counts for a set of stats lines.
+type Summary struct {
+	Dirs  int64 `json:"dirs"`
+	Files int64 `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// Summarise reads stats lines (as produced by 'wrstat stat') from r, and
+// returns a Summary of the number of directories, the number of other entries
+// (files, symlinks etc.) and the total size in bytes of those other entries.
+func Summarise(r io.Reader) (Summary, error) {
+	var s Summary
+
+	err := scanStatsLines(r, func(cols []string) error {
+		if cols[statsColType] == "d" {
+			s.Dirs++
+
+			return nil
+		}
+
+		size, err := strconv.ParseInt(cols[statsColSize], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		s.Files++
+		s.Bytes += size
+
+		return nil
+	})
+
+	return s, err
+}
@@ -0,0 +1,55 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSummarise(t *testing.T) {
+	Convey("Given some stats lines, you can summarise them", t, func() {
+		input := statsLine("/a/", 4096, "d") +
+			statsLine("/a/b/", 4096, "d") +
+			statsLine("/a/b/c.txt", 10, "f") +
+			statsLine("/a/b/d.txt", 20, "f") +
+			statsLine("/a/link", 5, "l")
+
+		s, err := Summarise(strings.NewReader(input))
+		So(err, ShouldBeNil)
+		So(s, ShouldResemble, Summary{Dirs: 2, Files: 3, Bytes: 35})
+
+		Convey("but not if they're malformed", func() {
+			_, err = Summarise(strings.NewReader("\"/a\"\t1\n"))
+			So(err, ShouldEqual, errBadStatsLine)
+
+			_, err = Summarise(strings.NewReader(strings.Replace(input, "10", "ten", 1)))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,165 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// the columns of a 'wrstat stat --modes' output line.
+const (
+	modesColPath = iota
+	modesColType
+	modesColPerms
+	modesCols
+)
+
+const (
+	groupWritable = 0o020
+	worldWritable = 0o002
+
+	errBadModesLine = Error("modes line does not have 3 columns")
+)
+
+// writableCount is the number of group and world writable files directly
+// within a directory.
+type writableCount struct {
+	dir   string
+	group int
+	world int
+}
+
+// writableCounter tracks the directories containing writable files that are
+// ancestors of (or the same as) the directory of the current path, as the
+// sorted input is streamed through.
+type writableCounter struct {
+	w     io.Writer
+	stack []*writableCount
+}
+
+// WritableCounts reads 'wrstat stat --modes' output from each of the given
+// inputs, and writes a report to w of the directories that directly contain
+// files (not directories or symlinks) that are group or world writable.
+//
+// Each line of the report is tab separated: the quoted directory (with a
+// trailing slash), the number of group writable files and the number of world
+// writable files.
+//
+// The inputs must each be sorted by path, as 'wrstat stat' output is. They are
+// merged and streamed through, with a directory's line being written once all
+// the entries beneath it have been seen, so a directory's line comes after
+// those of its subdirectories.
+func WritableCounts(inputs []io.Reader, w io.Writer) error {
+	merged, err := mergeSortedReaders(inputs, true, nil)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	wc := &writableCounter{w: bw}
+
+	err = scanColumnLines(merged, modesCols, errBadModesLine, func(cols []string) error {
+		if cols[modesColType] == "d" || cols[modesColType] == "l" {
+			return nil
+		}
+
+		return wc.add(cols)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = wc.popUntil(""); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// add counts the file described by the given columns if it is writable,
+// first writing out the counts of any directories we have finished with.
+func (wc *writableCounter) add(cols []string) error {
+	perms, err := strconv.ParseUint(cols[modesColPerms], 8, 32)
+	if err != nil {
+		return err
+	}
+
+	if perms&(groupWritable|worldWritable) == 0 {
+		return nil
+	}
+
+	path, err := strconv.Unquote(cols[modesColPath])
+	if err != nil {
+		return err
+	}
+
+	dir := strings.TrimSuffix(filepath.Dir(path), "/") + "/"
+
+	if err = wc.popUntil(dir); err != nil {
+		return err
+	}
+
+	if len(wc.stack) == 0 || wc.stack[len(wc.stack)-1].dir != dir {
+		wc.stack = append(wc.stack, &writableCount{dir: dir})
+	}
+
+	count := wc.stack[len(wc.stack)-1]
+
+	if perms&groupWritable != 0 {
+		count.group++
+	}
+
+	if perms&worldWritable != 0 {
+		count.world++
+	}
+
+	return nil
+}
+
+// popUntil writes out and forgets the counts of directories on our stack that
+// are not dir or an ancestor of it. Since the input is sorted, we will see no
+// more files in those directories. Supply a blank dir to write out everything.
+func (wc *writableCounter) popUntil(dir string) error {
+	for len(wc.stack) > 0 {
+		count := wc.stack[len(wc.stack)-1]
+		if dir != "" && strings.HasPrefix(dir, count.dir) {
+			return nil
+		}
+
+		if _, err := fmt.Fprintf(wc.w, "%q\t%d\t%d\n", count.dir, count.group, count.world); err != nil {
+			return err
+		}
+
+		wc.stack = wc.stack[:len(wc.stack)-1]
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package combine
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWritableCounts(t *testing.T) {
+	Convey("Given modes output from multiple stat jobs", t, func() {
+		input1 := `"/a/"	d	0777` + "\n" +
+			`"/a/b/"	d	0755` + "\n" +
+			`"/a/b/group"	f	0664` + "\n" +
+			`"/a/b/private"	f	0600` + "\n" +
+			`"/a/b/world"	f	0666` + "\n"
+		input2 := `"/a/c/"	d	0777` + "\n" +
+			`"/a/c/link"	l	0777` + "\n" +
+			`"/a/c/world"	F	1777` + "\n" +
+			`"/a/top"	f	0646` + "\n"
+
+		Convey("you can count the group and world writable files per directory", func() {
+			var out strings.Builder
+
+			err := WritableCounts([]io.Reader{strings.NewReader(input1), strings.NewReader(input2)}, &out)
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldEqual, `"/a/b/"	2	1`+"\n"+
+				`"/a/c/"	1	1`+"\n"+
+				`"/a/"	0	1`+"\n")
+		})
+
+		Convey("files either side of a subdirectory are counted in a single line", func() {
+			var out strings.Builder
+
+			input := `"/a/"	d	0755` + "\n" +
+				`"/a/b/"	d	0755` + "\n" +
+				`"/a/b/1"	f	0664` + "\n" +
+				`"/a/b/c/"	d	0755` + "\n" +
+				`"/a/b/c/2"	f	0666` + "\n" +
+				`"/a/b/d"	f	0664` + "\n" +
+				`"/a/bc/3"	f	0664` + "\n"
+
+			err := WritableCounts([]io.Reader{strings.NewReader(input)}, &out)
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldEqual, `"/a/b/c/"	1	1`+"\n"+
+				`"/a/b/"	2	0`+"\n"+
+				`"/a/bc/"	1	0`+"\n")
+		})
+
+		Convey("malformed input returns an error", func() {
+			var out strings.Builder
+
+			err := WritableCounts([]io.Reader{strings.NewReader(`"/a"	f` + "\n")}, &out)
+			So(err, ShouldEqual, errBadModesLine)
+
+			err = WritableCounts([]io.Reader{strings.NewReader(`"/a"	f	999` + "\n")}, &out)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
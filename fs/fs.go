@@ -62,6 +62,23 @@ func FindFilePathsInDir(dir, suffix string) ([]string, error) {
 	return paths, nil
 }
 
+// FindFilePathsInDirAndSubDirs is like FindFilePathsInDir(), but also finds
+// matching files in the immediate subdirectories of the given dir. It is still
+// an error if there are no matching files in dir itself.
+func FindFilePathsInDirAndSubDirs(dir, suffix string) ([]string, error) {
+	paths, err := FindFilePathsInDir(dir, suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	subPaths, err := filepath.Glob(fmt.Sprintf("%s/*/*%s", dir, suffix))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(paths, subPaths...), nil
+}
+
 // CreateOutputFileInDir creates a file for writing in the given dir with the
 // given basename.
 func CreateOutputFileInDir(dir, basename string) (*os.File, error) {
@@ -130,6 +147,26 @@ func FindOpenAndCreate(inputDir, outputDir, inputDirSuffix, outputDirSuffix stri
 		return nil, nil, err
 	}
 
+	return openAndCreate(paths, outputDir, outputDirSuffix)
+}
+
+// FindInDirAndSubDirsOpenAndCreate is like FindOpenAndCreate(), but input
+// filepaths are also located in the immediate subdirectories of the input
+// directory.
+func FindInDirAndSubDirsOpenAndCreate(inputDir, outputDir, inputDirSuffix,
+	outputDirSuffix string,
+) ([]*os.File, *os.File, error) {
+	paths, err := FindFilePathsInDirAndSubDirs(inputDir, inputDirSuffix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return openAndCreate(paths, outputDir, outputDirSuffix)
+}
+
+// openAndCreate opens the given paths for reading, and creates an output file
+// in outputDir with the given basename.
+func openAndCreate(paths []string, outputDir, outputDirSuffix string) ([]*os.File, *os.File, error) {
 	inputFiles, err := OpenFiles(paths)
 	if err != nil {
 		return nil, nil, err
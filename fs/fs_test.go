@@ -59,6 +59,55 @@ func TestFS(t *testing.T) {
 	})
 }
 
+func TestFindFilePathsInDirAndSubDirs(t *testing.T) {
+	Convey("Given files with a suffix in a dir and its subdirs", t, func() {
+		dir := t.TempDir()
+
+		for _, path := range []string{"a.stats", "b.log", "1/c.stats", "2/d.stats", "2/3/e.stats"} {
+			path = filepath.Join(dir, path)
+			So(os.MkdirAll(filepath.Dir(path), 0755), ShouldBeNil)
+			So(os.WriteFile(path, nil, 0600), ShouldBeNil)
+		}
+
+		Convey("you can find them in the dir and its immediate subdirs", func() {
+			paths, err := FindFilePathsInDirAndSubDirs(dir, ".stats")
+			So(err, ShouldBeNil)
+			So(paths, ShouldResemble, []string{
+				filepath.Join(dir, "a.stats"),
+				filepath.Join(dir, "1", "c.stats"),
+				filepath.Join(dir, "2", "d.stats"),
+			})
+
+			paths, err = FindFilePathsInDirAndSubDirs(filepath.Join(dir, "1"), ".stats")
+			So(err, ShouldBeNil)
+			So(paths, ShouldResemble, []string{filepath.Join(dir, "1", "c.stats")})
+		})
+
+		Convey("not finding any in the dir itself is an error", func() {
+			_, err := FindFilePathsInDirAndSubDirs(dir, ".foo")
+			So(err, ShouldNotBeNil)
+
+			So(os.WriteFile(filepath.Join(dir, "1", "f.foo"), nil, 0600), ShouldBeNil)
+
+			_, err = FindFilePathsInDirAndSubDirs(dir, ".foo")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("FindOpenAndCreate only finds them in the dir", func() {
+			inputs, output, err := FindOpenAndCreate(dir, t.TempDir(), ".stats", "out")
+			So(err, ShouldBeNil)
+			So(len(inputs), ShouldEqual, 1)
+			So(inputs[0].Name(), ShouldEqual, filepath.Join(dir, "a.stats"))
+			So(output.Close(), ShouldBeNil)
+
+			inputs, output, err = FindInDirAndSubDirsOpenAndCreate(dir, t.TempDir(), ".stats", "out")
+			So(err, ShouldBeNil)
+			So(len(inputs), ShouldEqual, 3)
+			So(output.Close(), ShouldBeNil)
+		})
+	})
+}
+
 // buildTestFiles builds two files, each with a line over 65536 chars long.
 func buildTestFiles(t *testing.T) ([]*os.File, *os.File, string) {
 	t.Helper()
@@ -38,6 +38,7 @@ import (
 	"os/exec"
 	"os/user"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"sort"
 	"strconv"
@@ -49,6 +50,7 @@ import (
 	"github.com/VertebrateResequencing/wr/jobqueue"
 	"github.com/VertebrateResequencing/wr/jobqueue/scheduler"
 	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-ssg/wrstat/v6/combine"
 )
 
 const app = "wrstat_test"
@@ -263,6 +265,189 @@ func multiTests(t *testing.T, subcommand ...string) {
 
 		So(jobs, ShouldResemble, expectation)
 	})
+
+	Convey("'wrstat multi' can override the walk requirements and job retries", func() {
+		workingDir := t.TempDir()
+		_, _, jobs, err := runWRStat(append(subcommand, "-w", workingDir, "/some/path",
+			"-f", "final_output", "--walk_ram", "32000", "--walk_time", "2h", "--retries", "5")...)
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 3)
+
+		So(jobs[0].ReqGroup, ShouldEqual, "wrstat-walk")
+		So(jobs[0].Requirements, ShouldResemble, &scheduler.Requirements{
+			RAM:   32000,
+			Time:  2 * time.Hour,
+			Cores: 1,
+			Disk:  1,
+		})
+		So(jobs[1].ReqGroup, ShouldEqual, "wrstat-combine")
+		So(jobs[1].Requirements, ShouldResemble, combineReqs)
+		So(jobs[2].ReqGroup, ShouldEqual, "wrstat-tidy")
+		So(jobs[2].Requirements, ShouldResemble, tidyReqs)
+
+		for _, job := range jobs {
+			So(job.Retries, ShouldEqual, 5)
+		}
+
+		So(jobs[0].Cmd, ShouldContainSubstring, " --retries 5 ")
+		So(jobs[1].Cmd, ShouldNotContainSubstring, "--retries")
+	})
+
+	Convey("'wrstat multi' can add a notification job that runs after tidy", func() {
+		workingDir := t.TempDir()
+		_, _, jobs, err := runWRStat(append(subcommand, "-w", workingDir, "/some/path",
+			"-f", "final_output", "--notify_cmd", "echo done | mail -s wrstat me@example.com")...)
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 4)
+
+		tidy, notify := jobs[2], jobs[3]
+		So(tidy.ReqGroup, ShouldEqual, "wrstat-tidy")
+		So(len(tidy.DepGroups), ShouldEqual, 1)
+
+		So(notify.Cmd, ShouldEqual, "echo done | mail -s wrstat me@example.com")
+		So(notify.ReqGroup, ShouldEqual, "wrstat-notify")
+		So(notify.RepGroup, ShouldStartWith, "wrstat-notify-final_output-")
+		So(notify.Dependencies, ShouldResemble, jobqueue.Dependencies{{DepGroup: tidy.DepGroups[0]}})
+		So(notify.DepGroups, ShouldBeNil)
+	})
+
+	Convey("'wrstat multi' can limit the number of walks that run at once", func() {
+		workingDir := t.TempDir()
+		_, _, jobs, err := runWRStat(append(subcommand, "-w", workingDir, "/some/path", "/some-other/path",
+			"-f", "final_output", "--parallel_walks", "1")...)
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 5)
+
+		for _, job := range jobs {
+			if job.ReqGroup == "wrstat-walk" {
+				So(job.LimitGroups, ShouldResemble, []string{"wrstat-walk:1"})
+			} else {
+				So(job.LimitGroups, ShouldBeNil)
+			}
+		}
+	})
+
+	Convey("'wrstat multi' can split directories of interest in to separate walks", func() {
+		workingDir := t.TempDir()
+		tmp := t.TempDir()
+
+		for _, dir := range [...]string{"a/b", "a/c/d", "e"} {
+			So(os.MkdirAll(filepath.Join(tmp, dir), 0755), ShouldBeNil)
+		}
+
+		writeFileString(t, filepath.Join(tmp, "a", "file"), "")
+		writeFileString(t, filepath.Join(tmp, "file"), "")
+
+		_, _, jobs, err := runWRStat(append(subcommand, "-w", workingDir, tmp,
+			"-f", "final_output", "--root_depth_split", "2", "--dir_blocks")...)
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 5)
+
+		stat, walkB, walkC, combineJob := jobs[0], jobs[1], jobs[2], jobs[3]
+		So(stat.ReqGroup, ShouldEqual, "wrstat-stat")
+		So(stat.Cmd, ShouldContainSubstring, " stat --dir_blocks ")
+		So(stat.Cmd, ShouldEndWith, "/walk.shallow")
+		So(len(stat.DepGroups), ShouldEqual, 1)
+
+		outDir := filepath.Dir(stat.Cmd[strings.LastIndex(stat.Cmd, " ")+1:])
+
+		for i, walk := range []*jobqueue.Job{walkB, walkC} {
+			path := filepath.Join(tmp, "a", string(rune('b'+i))) + "/"
+
+			So(walk.ReqGroup, ShouldEqual, "wrstat-walk")
+			So(walk.DepGroups, ShouldResemble, stat.DepGroups)
+			So(walk.Cmd, ShouldContainSubstring, " -o "+filepath.Join(outDir, strconv.Itoa(i+1))+" ")
+			So(walk.Cmd, ShouldEndWith, " "+path)
+		}
+
+		So(combineJob.ReqGroup, ShouldEqual, "wrstat-combine")
+		So(combineJob.Cmd, ShouldEndWith, " combine --subdirs "+outDir)
+		So(combineJob.Dependencies, ShouldResemble, jobqueue.Dependencies{{DepGroup: stat.DepGroups[0]}})
+
+		compareFileContents(t, filepath.Join(outDir, "walk.shallow"), strings.Join([]string{
+			strconv.Quote(tmp + "/"),
+			strconv.Quote(tmp + "/a/"),
+			strconv.Quote(tmp + "/a/file"),
+			strconv.Quote(tmp + "/e/"),
+			strconv.Quote(tmp + "/file"),
+		}, "\n")+"\n")
+	})
+
+	Convey("'wrstat multi' passes stat options through to walk", func() {
+		workingDir := t.TempDir()
+		_, _, jobs, err := runWRStat(append(subcommand, "-w", workingDir, "/some/path",
+			"-f", "final_output", "--dir_blocks", "--uid_map", "/uids", "--gid_map", "/gids")...)
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 3)
+		So(jobs[0].Cmd, ShouldContainSubstring,
+			" walk -n 1000000 --dir_blocks --uid_map /uids --gid_map /gids  -d ")
+	})
+
+	Convey("'wrstat multi' can record the jobs it submits", func() {
+		workingDir := t.TempDir()
+		jobsOutput := filepath.Join(t.TempDir(), "jobs.json")
+
+		_, _, jobs, err := runWRStat(append(subcommand, "-w", workingDir, "/some/path", "/some-other/path",
+			"-f", "final_output", "--jobs_output", jobsOutput)...)
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 5)
+
+		f, err := os.Open(jobsOutput)
+		So(err, ShouldBeNil)
+
+		defer f.Close()
+
+		var recorded []*jobqueue.Job
+
+		So(json.NewDecoder(f).Decode(&recorded), ShouldBeNil)
+		So(recorded, ShouldResemble, jobs)
+
+		entries, err := os.ReadDir(filepath.Dir(jobsOutput))
+		So(err, ShouldBeNil)
+		So(len(entries), ShouldEqual, 1)
+	})
+
+	Convey("'wrstat multi' can append a record of the jobs it submits for each run", func() {
+		workingDir := t.TempDir()
+		jobsOutput := filepath.Join(t.TempDir(), "jobs.jsonl")
+		args := []string{"-w", workingDir, "-f", "final_output", "--jobs_output", jobsOutput, "--jobs_output_append"}
+
+		_, _, jobs, err := runWRStat(append(append(subcommand, args...), "/some/path", "/some-other/path")...)
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 5)
+
+		_, _, secondJobs, err := runWRStat(append(append(subcommand, args...), "/some/path")...)
+		So(err, ShouldBeNil)
+		So(len(secondJobs), ShouldEqual, 3)
+
+		data, err := os.ReadFile(jobsOutput)
+		So(err, ShouldBeNil)
+
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		So(len(lines), ShouldEqual, 2)
+
+		var first, second struct {
+			ID   string          `json:"id"`
+			Jobs []*jobqueue.Job `json:"jobs"`
+		}
+
+		So(json.Unmarshal([]byte(lines[0]), &first), ShouldBeNil)
+		So(json.Unmarshal([]byte(lines[1]), &second), ShouldBeNil)
+		So(first.Jobs, ShouldResemble, jobs)
+		So(second.Jobs, ShouldResemble, secondJobs)
+		So(first.ID, ShouldNotBeBlank)
+		So(second.ID, ShouldNotEqual, first.ID)
+		So(jobs[0].Cmd, ShouldContainSubstring, first.ID)
+	})
+
+	Convey("'wrstat multi' passes --prune_dirs through to walk", func() {
+		workingDir := t.TempDir()
+		_, _, jobs, err := runWRStat(append(subcommand, "-w", workingDir, "/some/path",
+			"-f", "final_output", "--prune_dirs", ".git,node_modules")...)
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 3)
+		So(jobs[0].Cmd, ShouldContainSubstring, " walk -n 1000000 --prune_dirs .git,node_modules  -d ")
+	})
 }
 
 func TestMulti(t *testing.T) {
@@ -328,6 +513,14 @@ func TestWalk(t *testing.T) {
 
 		compareFileContents(t, walk1, expected)
 
+		log, err := os.ReadFile(filepath.Join(out, "walk.log"))
+		So(err, ShouldBeNil)
+		So(string(log), ShouldContainSubstring, `msg="walk complete"`)
+		So(string(log), ShouldContainSubstring, " entries=12 ")
+		So(string(log), ShouldContainSubstring, " entries_per_sec=")
+		So(regexp.MustCompile(` start=\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\S* end=\d{4}-\d{2}-\d{2}T`).
+			MatchString(string(log)), ShouldBeTrue)
+
 		_, _, jobs, err = runWRStat("walk", tmp, "-o", out, "-d", depgroup, "-j", "2")
 		So(err, ShouldBeNil)
 
@@ -371,9 +564,90 @@ func TestWalk(t *testing.T) {
 		removeJobRepGroupSuffixes(jobs)
 
 		So(jobs, ShouldResemble, jobsExpectation)
+
+		_, _, jobs, err = runWRStat("walk", tmp, "-o", out, "-d", depgroup, "-j", "1", "--dir_blocks")
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 1)
+		So(jobs[0].Cmd, ShouldEqual, exe+" stat --dir_blocks "+walk1)
+
+		_, _, jobs, err = runWRStat("walk", tmp, "-o", out, "-d", depgroup, "-j", "1", "--symlinks")
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 1)
+		So(jobs[0].Cmd, ShouldEqual, exe+" stat --symlinks "+walk1)
+
+		_, _, jobs, err = runWRStat("walk", tmp, "-o", out, "-d", depgroup, "-j", "1", "--modes")
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 1)
+		So(jobs[0].Cmd, ShouldEqual, exe+" stat --modes "+walk1)
+
+		link := filepath.Join(t.TempDir(), "link")
+		So(os.Symlink(tmp, link), ShouldBeNil)
+
+		_, _, jobs, err = runWRStat("walk", link, "-o", out, "-d", depgroup, "-j", "1", "--canonicalise")
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 1)
+		So(jobs[0].Cmd, ShouldEqual, exe+" stat --canonicalise "+walk1)
+
+		resolvedTmp, err := filepath.EvalSymlinks(tmp)
+		So(err, ShouldBeNil)
+
+		walked, err := os.ReadFile(walk1)
+		So(err, ShouldBeNil)
+		So(string(walked), ShouldStartWith, strconv.Quote(resolvedTmp+"/")+"\n")
+		So(strings.Count(string(walked), "\n"), ShouldEqual, 12)
+
+		_, _, jobs, err = runWRStat("walk", tmp, "-o", out, "-d", depgroup, "-j", "1", "--nlink_threshold", "5")
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 1)
+		So(jobs[0].Cmd, ShouldEqual, exe+" stat --nlink_threshold 5 "+walk1)
+
+		_, _, jobs, err = runWRStat("walk", tmp, "-o", out, "-d", depgroup, "-j", "1", "--retries", "5")
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 1)
+		So(jobs[0].Cmd, ShouldEqual, exe+" stat "+walk1)
+		So(jobs[0].Retries, ShouldEqual, 5)
+
+		stdout, _, jobs, err := runWRStat("walk", tmp, "--count_only")
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 0)
+		So(stdout, ShouldEqual, `{"dirs":9,"files":3}`+"\n")
+
+		stdout, _, _, err = runWRStat("walk", tmp, "--count_only", "--prune_dirs", "b")
+		So(err, ShouldBeNil)
+		So(stdout, ShouldEqual, `{"dirs":5,"files":1}`+"\n")
+
+		_, _, _, err = runWRStat("walk", tmp, "-o", out, "-d", depgroup, "-j", "1", "--prune_dirs", "b,g")
+		So(err, ShouldBeNil)
+
+		expected = ""
+		for _, subPath := range []string{"/", "/a/", "/a/b/", "/a/g/", "/a/test3"} {
+			expected += strconv.Quote(tmp+subPath) + "\n"
+		}
+
+		compareFileContents(t, walk1, expected)
+
+		dirsOutput := filepath.Join(t.TempDir(), "dirs")
+
+		_, _, _, err = runWRStat("walk", tmp, "-o", out, "-d", depgroup, "-j", "2", "--dirs_output", dirsOutput)
+		So(err, ShouldBeNil)
+
+		expected = ""
+		for _, subPath := range []string{
+			"/", "/a/", "/a/b/", "/a/b/c/", "/a/b/c/d/", "/a/b/c/d/e/", "/a/b/f/", "/a/g/", "/a/g/h/",
+		} {
+			expected += strconv.Quote(tmp+subPath) + "\n"
+		}
+
+		compareFileContents(t, dirsOutput, expected)
 	})
 }
 
+// statsLine returns a 'wrstat stat' style line for the given path, size, type
+// and UID, with fixed values for the other columns.
+func statsLine(path string, size int, typ string, uid int) string {
+	return fmt.Sprintf("%q\t%d\t%d\t2\t3\t4\t5\t%s\t6\t1\t7\n", path, size, uid, typ)
+}
+
 func writeFileString(t *testing.T, path, contents string) {
 	t.Helper()
 
@@ -561,6 +835,208 @@ func TestStat(t *testing.T) {
 		So(err, ShouldBeNil)
 		So(string(data), ShouldEqual, statsExpectation)
 	})
+
+	Convey("Given a walk file with a vanished path, stat skips it unless --fail_fast", t, func() {
+		workDir := t.TempDir()
+		walkFilePath := filepath.Join(workDir, "vanished.walk")
+
+		writeFileString(t, walkFilePath, strconv.Quote(tmp)+"\n"+
+			strconv.Quote(filepath.Join(tmp, "vanished"))+"\n"+
+			strconv.Quote(filepath.Join(tmp, "aDirectory"))+"\n")
+
+		_, _, _, err := runWRStat("stat", walkFilePath)
+		So(err, ShouldBeNil)
+
+		data, err := os.ReadFile(walkFilePath + ".stats")
+		So(err, ShouldBeNil)
+		So(strings.Count(string(data), "\n"), ShouldEqual, 2)
+		So(string(data), ShouldNotContainSubstring, "vanished")
+
+		logs, err := os.ReadFile(walkFilePath + ".log")
+		So(err, ShouldBeNil)
+		So(string(logs), ShouldContainSubstring, "skipped 1 paths that could not be statted")
+
+		_, _, _, err = runWRStat("stat", "--fail_fast", walkFilePath)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Given a walk file with symlinks, stat --symlinks reports them", t, func() {
+		workDir := t.TempDir()
+		target := filepath.Join(workDir, "target")
+		valid := filepath.Join(workDir, "valid")
+		broken := filepath.Join(workDir, "broken")
+
+		writeFileString(t, target, "")
+		So(os.Symlink(target, valid), ShouldBeNil)
+		So(os.Symlink("/non/existent", broken), ShouldBeNil)
+
+		walkFilePath := filepath.Join(workDir, "links.walk")
+		writeFileString(t, walkFilePath, strconv.Quote(broken)+"\n"+
+			strconv.Quote(target)+"\n"+strconv.Quote(valid)+"\n")
+
+		_, _, _, err := runWRStat("stat", "--symlinks", walkFilePath)
+		So(err, ShouldBeNil)
+
+		data, err := os.ReadFile(walkFilePath + ".symlinks")
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, strconv.Quote(broken)+"\t\"/non/existent\"\tbroken\n"+
+			strconv.Quote(valid)+"\t"+strconv.Quote(target)+"\tok\n")
+	})
+
+	Convey("Given uid and gid map files, stat translates the ids it outputs", t, func() {
+		u, err := user.Current()
+		So(err, ShouldBeNil)
+
+		workDir := t.TempDir()
+		uidMap := filepath.Join(workDir, "uids")
+		gidMap := filepath.Join(workDir, "gids")
+		walkFilePath := filepath.Join(workDir, "ids.walk")
+
+		writeFileString(t, uidMap, "# source target\n"+u.Uid+" 12345\n")
+		writeFileString(t, gidMap, "99999 1\n")
+		writeFileString(t, walkFilePath, strconv.Quote(tmp)+"\n")
+
+		_, _, _, err = runWRStat("stat", "--uid_map", uidMap, "--gid_map", gidMap, walkFilePath)
+		So(err, ShouldBeNil)
+
+		data, err := os.ReadFile(walkFilePath + ".stats")
+		So(err, ShouldBeNil)
+		So(strings.Split(string(data), "\t")[2:4], ShouldResemble, []string{"12345", u.Gid})
+
+		writeFileString(t, uidMap, "bad\n")
+
+		_, _, _, err = runWRStat("stat", "--uid_map", uidMap, walkFilePath)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Given paths on STDIN and an --output, stat gives the same output as for a file", t, func() {
+		workDir := t.TempDir()
+		paths := strconv.Quote(tmp) + "\n" + strconv.Quote(filepath.Join(tmp, "aDirectory")) + "\n"
+		walkFilePath := filepath.Join(workDir, "file.walk")
+		writeFileString(t, walkFilePath, paths)
+
+		_, _, _, err := runWRStat("stat", walkFilePath)
+		So(err, ShouldBeNil)
+
+		expected, err := os.ReadFile(walkFilePath + ".stats")
+		So(err, ShouldBeNil)
+
+		prefix := filepath.Join(workDir, "stdin")
+		cmd := exec.Command("./"+app, "stat", "-o", prefix, "-")
+		cmd.Stdin = strings.NewReader(paths)
+		So(cmd.Run(), ShouldBeNil)
+
+		data, err := os.ReadFile(prefix + ".stats")
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, string(expected))
+
+		_, err = os.Stat(prefix + ".log")
+		So(err, ShouldBeNil)
+
+		_, _, _, err = runWRStat("stat", "-")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Given a walk file with non-canonical paths, stat --canonicalise cleans them", t, func() {
+		workDir := t.TempDir()
+		walkFilePath := filepath.Join(workDir, "canonical.walk")
+		writeFileString(t, walkFilePath, strconv.Quote(tmp+"/aDirectory/../anotherDirectory/")+"\n")
+
+		_, _, _, err := runWRStat("stat", walkFilePath)
+		So(err, ShouldBeNil)
+
+		data, err := os.ReadFile(walkFilePath + ".stats")
+		So(err, ShouldBeNil)
+		So(string(data), ShouldStartWith, strconv.Quote(tmp+"/aDirectory/../anotherDirectory/")+"\t")
+
+		_, _, _, err = runWRStat("stat", "--canonicalise", walkFilePath)
+		So(err, ShouldBeNil)
+
+		data, err = os.ReadFile(walkFilePath + ".stats")
+		So(err, ShouldBeNil)
+		So(string(data), ShouldStartWith, strconv.Quote(tmp+"/anotherDirectory/")+"\t")
+	})
+
+	Convey("Given a walk file with hard linked files, stat --nlink_threshold reports them", t, func() {
+		dataDir := t.TempDir()
+		workDir := t.TempDir()
+		shared := filepath.Join(dataDir, "shared")
+		single := filepath.Join(dataDir, "single")
+
+		writeFileString(t, shared, "")
+		writeFileString(t, single, "")
+
+		for i := range 3 {
+			So(os.Link(shared, filepath.Join(dataDir, fmt.Sprintf("link%d", i))), ShouldBeNil)
+		}
+
+		walkFilePath := filepath.Join(workDir, "nlink.walk")
+		writeFileString(t, walkFilePath, strconv.Quote(dataDir+"/")+"\n"+
+			strconv.Quote(shared)+"\n"+strconv.Quote(single)+"\n")
+
+		_, _, _, err := runWRStat("stat", "--nlink_threshold", "2", walkFilePath)
+		So(err, ShouldBeNil)
+
+		data, err := os.ReadFile(walkFilePath + ".nlinks")
+		So(err, ShouldBeNil)
+		So(string(data), ShouldStartWith, strconv.Quote(shared)+"\t4\t")
+		So(strings.Count(string(data), "\n"), ShouldEqual, 1)
+	})
+
+	Convey("Given a walk file, stat --trim_prefix and --add_prefix rewrite the output paths", t, func() {
+		workDir := t.TempDir()
+		walkFilePath := filepath.Join(workDir, "prefix.walk")
+		writeFileString(t, walkFilePath, strconv.Quote(tmp+"/")+"\n"+
+			strconv.Quote(filepath.Join(tmp, "aDirectory"))+"\n"+strconv.Quote(workDir)+"\n")
+
+		_, _, _, err := runWRStat("stat", "--trim_prefix", tmp+"/", "--add_prefix", "/new/", walkFilePath)
+		So(err, ShouldBeNil)
+
+		data, err := os.ReadFile(walkFilePath + ".stats")
+		So(err, ShouldBeNil)
+
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		So(len(lines), ShouldEqual, 2)
+		So(lines[0], ShouldStartWith, `"/new/"`+"\t")
+		So(lines[1], ShouldStartWith, `"/new/aDirectory"`+"\t")
+
+		for _, args := range [][]string{
+			{"--add_prefix", "/new/"},
+			{"--trim_prefix", tmp, "--add_prefix", "/new/"},
+			{"--trim_prefix", tmp + "/", "--add_prefix", "new/"},
+		} {
+			_, _, _, err = runWRStat(append(append([]string{"stat"}, args...), walkFilePath)...)
+			So(err, ShouldNotBeNil)
+		}
+	})
+
+	Convey("Given a walk file with a world writable file, stat --modes and combine --writable report it", t, func() {
+		dataDir := t.TempDir()
+		workDir := t.TempDir()
+		world := filepath.Join(dataDir, "world")
+		private := filepath.Join(dataDir, "private")
+
+		writeFileString(t, world, "")
+		writeFileString(t, private, "")
+		So(os.Chmod(world, 0666), ShouldBeNil)
+		So(os.Chmod(private, 0600), ShouldBeNil)
+
+		walkFilePath := filepath.Join(workDir, "modes.walk")
+		writeFileString(t, walkFilePath, strconv.Quote(dataDir+"/")+"\n"+
+			strconv.Quote(private)+"\n"+strconv.Quote(world)+"\n")
+
+		_, _, _, err := runWRStat("stat", "--modes", walkFilePath)
+		So(err, ShouldBeNil)
+
+		data, err := os.ReadFile(walkFilePath + ".modes")
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEndWith, strconv.Quote(private)+"\tf\t0600\n"+strconv.Quote(world)+"\tf\t0666\n")
+
+		_, _, _, err = runWRStat("combine", "--writable", workDir)
+		So(err, ShouldBeNil)
+
+		compareFileContents(t, filepath.Join(workDir, "combine.writable"), strconv.Quote(dataDir+"/")+"\t1\t1")
+	})
 }
 
 func TestCombine(t *testing.T) {
@@ -608,6 +1084,269 @@ func TestCombine(t *testing.T) {
 	})
 }
 
+func TestCombineSubDirs(t *testing.T) {
+	Convey("For the combine subcommand, --subdirs also combines files in subdirectories", t, func() {
+		tmp := t.TempDir()
+
+		So(os.MkdirAll(filepath.Join(tmp, "1"), 0755), ShouldBeNil)
+
+		writeFileString(t, filepath.Join(tmp, "walk.shallow.stats"), statsLine("/x/", 1, "d", 1))
+		writeFileString(t, filepath.Join(tmp, "walk.shallow.log"), "")
+		writeFileString(t, filepath.Join(tmp, "1", "walk.1.stats"), statsLine("/x/a/", 1, "d", 1))
+		writeFileString(t, filepath.Join(tmp, "1", "walk.1.log"), "")
+
+		_, _, _, err := runWRStat("combine", tmp)
+		So(err, ShouldBeNil)
+
+		compareFileContents(t, filepath.Join(tmp, "combine.stats.gz"), statsLine("/x/", 1, "d", 1))
+
+		_, _, _, err = runWRStat("combine", "--subdirs", tmp)
+		So(err, ShouldBeNil)
+
+		compareFileContents(t, filepath.Join(tmp, "combine.stats.gz"),
+			statsLine("/x/", 1, "d", 1)+statsLine("/x/a/", 1, "d", 1))
+
+		So(os.Remove(filepath.Join(tmp, "walk.shallow.stats")), ShouldBeNil)
+
+		_, _, _, err = runWRStat("combine", "--subdirs", tmp)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestCombineDuplicates(t *testing.T) {
+	Convey("For the combine subcommand, --duplicates reports duplicate filenames", t, func() {
+		tmp := t.TempDir()
+
+		writeFileString(t, filepath.Join(tmp, "a.stats"),
+			statsLine("/x/a/data.bam", 2000, "f", 1)+statsLine("/x/a/small", 1, "f", 1))
+		writeFileString(t, filepath.Join(tmp, "b.stats"),
+			statsLine("/x/b/data.bam", 2000, "f", 1)+statsLine("/x/b/small", 1, "f", 1))
+		writeFileString(t, filepath.Join(tmp, "a.log"), "")
+
+		_, _, _, err := runWRStat("combine", "--duplicates", "2", "--duplicates_min_size", "1000", tmp)
+		So(err, ShouldBeNil)
+
+		compareFileContents(t, filepath.Join(tmp, "combine.duplicates"),
+			`"data.bam"	2000	2	"/x/a/data.bam"	"/x/b/data.bam"`)
+	})
+}
+
+func TestCombineProgress(t *testing.T) {
+	Convey("For the combine subcommand, --progress logs the progress of the merge", t, func() {
+		tmp := t.TempDir()
+
+		for i := range 5 {
+			writeFileString(t, filepath.Join(tmp, fmt.Sprintf("%d.stats", i)), fmt.Sprintf("\"/x/%d\"\n", i))
+		}
+
+		writeFileString(t, filepath.Join(tmp, "a.log"), "")
+
+		_, stderr, _, err := runWRStat("combine", "--progress", tmp)
+		So(err, ShouldBeNil)
+		So(stderr, ShouldContainSubstring, "combined 5 of 5 stats files; ")
+		So(stderr, ShouldContainSubstring, " bytes written")
+
+		_, stderr, _, err = runWRStat("combine", tmp)
+		So(err, ShouldBeNil)
+		So(stderr, ShouldNotContainSubstring, "combined ")
+	})
+}
+
+func TestCombineSummaryLine(t *testing.T) {
+	Convey("For the combine subcommand, --summary_line prints a summary of the stats", t, func() {
+		tmp := t.TempDir()
+
+		writeFileString(t, filepath.Join(tmp, "a.stats"), statsLine("/x/", 4096, "d", 1)+statsLine("/x/a", 100, "f", 1))
+		writeFileString(t, filepath.Join(tmp, "b.stats"), statsLine("/x/b/", 4096, "d", 1)+statsLine("/x/b/c", 20, "f", 1)+
+			statsLine("/x/d", 3, "l", 1))
+		writeFileString(t, filepath.Join(tmp, "a.log"), "")
+
+		stdout, _, _, err := runWRStat("combine", "--summary_line", tmp)
+		So(err, ShouldBeNil)
+		So(stdout, ShouldEqual, `{"dirs":2,"files":3,"bytes":123}`+"\n")
+
+		stdout, _, _, err = runWRStat("combine", tmp)
+		So(err, ShouldBeNil)
+		So(stdout, ShouldBeEmpty)
+	})
+}
+
+func TestCombineExtensions(t *testing.T) {
+	Convey("For the combine subcommand, --extensions reports file extension tallies", t, func() {
+		tmp := t.TempDir()
+
+		writeFileString(t, filepath.Join(tmp, "a.stats"),
+			statsLine("/x/a.bam", 2000, "f", 1)+statsLine("/x/b.txt", 1, "f", 1))
+		writeFileString(t, filepath.Join(tmp, "b.stats"),
+			statsLine("/x/c.BAM", 3000, "f", 1)+statsLine("/x/d.csv", 5, "f", 1))
+		writeFileString(t, filepath.Join(tmp, "a.log"), "")
+
+		_, _, _, err := runWRStat("combine", "--extensions", "1", tmp)
+		So(err, ShouldBeNil)
+
+		data, err := os.ReadFile(filepath.Join(tmp, "combine.extensions"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "\"bam\"\t2\t5000\nother\t2\t6\n")
+	})
+}
+
+func TestCombineIndex(t *testing.T) {
+	Convey("For the combine subcommand, --index lets you read the stats of a top-level directory", t, func() {
+		tmp := t.TempDir()
+
+		root := statsLine("/x/", 1, "f", 1)
+		a := statsLine("/x/a/", 1, "f", 1) + statsLine("/x/a/1", 1, "f", 1)
+		b := statsLine("/x/b/", 1, "f", 1) + statsLine("/x/b/2", 1, "f", 1) + statsLine("/x/b/3", 1, "f", 1)
+		c := statsLine("/x/c", 1, "f", 1)
+
+		writeFileString(t, filepath.Join(tmp, "a.stats"), root+a+c)
+		writeFileString(t, filepath.Join(tmp, "b.stats"), b)
+		writeFileString(t, filepath.Join(tmp, "a.log"), "")
+
+		_, stderr, _, err := runWRStat("combine", "--index", tmp)
+		So(err, ShouldNotBeNil)
+		So(stderr, ShouldContainSubstring, "--root is required")
+
+		_, _, _, err = runWRStat("combine", "--index", "--root", "/x", "--duplicates", "2", tmp)
+		So(err, ShouldBeNil)
+
+		compareFileContents(t, filepath.Join(tmp, "combine.stats.gz"), root+a+b+c)
+
+		stats, err := os.Open(filepath.Join(tmp, "combine.stats.gz"))
+		So(err, ShouldBeNil)
+
+		defer stats.Close()
+
+		index, err := os.Open(filepath.Join(tmp, "combine.stats.index"))
+		So(err, ShouldBeNil)
+
+		defer index.Close()
+
+		r, err := combine.StatsForPath(stats, index, "/x/b/")
+		So(err, ShouldBeNil)
+
+		data, err := io.ReadAll(r)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, b)
+
+		_, err = os.Stat(filepath.Join(tmp, "combine.stats.gz.tmp"))
+		So(err, ShouldNotBeNil)
+
+		_, stderr, _, err = runWRStat("combine", "--index", "--root", "/x", "--progress", tmp)
+		So(err, ShouldBeNil)
+		So(stderr, ShouldContainSubstring, "combined 2 of 2 stats files; ")
+
+		compareFileContents(t, filepath.Join(tmp, "combine.stats.gz"), root+a+b+c)
+	})
+}
+
+func TestCombineDirBlocks(t *testing.T) {
+	Convey("For the combine subcommand, stats made with and without --dir_blocks can't be mixed", t, func() {
+		dataDir := t.TempDir()
+		tmp := t.TempDir()
+
+		walk1 := filepath.Join(tmp, "walk.1")
+		walk2 := filepath.Join(tmp, "walk.2")
+
+		writeFileString(t, walk1, strconv.Quote(dataDir+"/")+"\n")
+		writeFileString(t, walk2, strconv.Quote(filepath.Join(dataDir, "file"))+"\n")
+		writeFileString(t, filepath.Join(dataDir, "file"), "")
+
+		_, _, _, err := runWRStat("stat", "--dir_blocks", walk1)
+		So(err, ShouldBeNil)
+
+		_, err = os.Stat(walk1 + ".dirblocks")
+		So(err, ShouldBeNil)
+
+		_, _, _, err = runWRStat("stat", walk2)
+		So(err, ShouldBeNil)
+
+		_, stderr, _, err := runWRStat("combine", tmp)
+		So(err, ShouldNotBeNil)
+		So(stderr, ShouldContainSubstring, "1 of 2 stats files were made with --dir_blocks")
+
+		_, _, _, err = runWRStat("stat", "--dir_blocks", walk2)
+		So(err, ShouldBeNil)
+
+		_, _, _, err = runWRStat("combine", tmp)
+		So(err, ShouldBeNil)
+
+		_, _, _, err = runWRStat("stat", walk1)
+		So(err, ShouldBeNil)
+
+		_, err = os.Stat(walk1 + ".dirblocks")
+		So(err, ShouldNotBeNil)
+
+		_, _, _, err = runWRStat("combine", tmp)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestCombineSplitByUID(t *testing.T) {
+	Convey("For the combine subcommand, --split_by_uid splits the output by UID", t, func() {
+		tmp := t.TempDir()
+
+		writeFileString(t, filepath.Join(tmp, "a.stats"),
+			statsLine("/x/", 1, "f", 0)+statsLine("/x/a", 1, "f", 100)+statsLine("/x/c", 1, "f", 200))
+		writeFileString(t, filepath.Join(tmp, "b.stats"),
+			statsLine("/x/b", 1, "f", 100)+statsLine("/x/d", 1, "f", 0))
+		writeFileString(t, filepath.Join(tmp, "a.log"), "")
+
+		_, _, _, err := runWRStat("combine", "--split_by_uid", tmp)
+		So(err, ShouldBeNil)
+
+		compareFileContents(t, filepath.Join(tmp, "combine.stats.uid0.gz"),
+			statsLine("/x/", 1, "f", 0)+statsLine("/x/d", 1, "f", 0))
+		compareFileContents(t, filepath.Join(tmp, "combine.stats.uid100.gz"),
+			statsLine("/x/a", 1, "f", 100)+statsLine("/x/b", 1, "f", 100))
+		compareFileContents(t, filepath.Join(tmp, "combine.stats.uid200.gz"),
+			statsLine("/x/c", 1, "f", 200))
+
+		paths, err := filepath.Glob(filepath.Join(tmp, "combine.stats.uid*.gz"))
+		So(err, ShouldBeNil)
+		So(len(paths), ShouldEqual, 3)
+	})
+}
+
+func TestCombineShard(t *testing.T) {
+	Convey("For the combine subcommand, --shard splits the output by top-level directory", t, func() {
+		tmp := t.TempDir()
+
+		writeFileString(t, filepath.Join(tmp, "a.stats"), statsLine("/x/", 1, "f", 1)+
+			statsLine("/x/a/", 1, "f", 1)+statsLine("/x/a/1", 1, "f", 1)+statsLine("/x/c/3", 1, "f", 1))
+		writeFileString(t, filepath.Join(tmp, "b.stats"), statsLine("/x/b/", 1, "f", 1)+
+			statsLine("/x/b/2", 1, "f", 1)+statsLine("/x/d", 1, "f", 1)+statsLine("/x/e/4", 1, "f", 1))
+		writeFileString(t, filepath.Join(tmp, "a.log"), "")
+
+		_, stderr, _, err := runWRStat("combine", "--shard", "3", tmp)
+		So(err, ShouldNotBeNil)
+		So(stderr, ShouldContainSubstring, "--root is required")
+
+		_, _, _, err = runWRStat("combine", "--shard", "3", "--root", "/x", tmp)
+		So(err, ShouldBeNil)
+
+		union := ""
+
+		for i := range 3 {
+			f, errr := os.Open(filepath.Join(tmp, fmt.Sprintf("combine.stats.shard%d.gz", i)))
+			So(errr, ShouldBeNil)
+
+			r, errr := gzip.NewReader(f)
+			So(errr, ShouldBeNil)
+
+			data, errr := io.ReadAll(r)
+			So(errr, ShouldBeNil)
+
+			f.Close()
+
+			union += string(data)
+		}
+
+		So(strings.Count(union, "\n"), ShouldEqual, 8)
+		compareFileContents(t, filepath.Join(tmp, "combine.stats.gz"), union)
+	})
+}
+
 func TestTidy(t *testing.T) {
 	Convey("For the tidy command, combine files within the source directory "+
 		"are cleaned up and moved to the final directory", t, func() {
@@ -650,6 +1389,39 @@ func TestTidy(t *testing.T) {
 			So(string(contents), ShouldEqual, expected)
 		}
 	})
+
+	Convey("For the tidy command, --no_remove moves the combine files but keeps the source directory", t, func() {
+		srcDir := t.TempDir()
+		finalDir := t.TempDir()
+		walkLog := filepath.Join(srcDir, "a", "b", "walk.log")
+
+		for _, file := range [...]string{
+			filepath.Join("a", "b", "combine.stats.gz"),
+			filepath.Join("a", "b", "combine.log.gz"),
+			filepath.Join("a", "b", "walk.log"),
+		} {
+			fp := filepath.Join(srcDir, file)
+			So(os.MkdirAll(filepath.Dir(fp), 0755), ShouldBeNil)
+
+			writeFileString(t, fp, file)
+		}
+
+		_, _, _, err := runWRStat("tidy", "-d", "today", "-f", finalDir, "--no_remove", srcDir)
+		So(err, ShouldBeNil)
+
+		_, err = os.Stat(walkLog)
+		So(err, ShouldBeNil)
+
+		_, err = os.Stat(filepath.Join(srcDir, "a", "b", "combine.stats.gz"))
+		So(err, ShouldNotBeNil)
+
+		data, err := os.ReadFile(filepath.Join(finalDir, "today_a.b."+filepath.Base(srcDir)+".stats.gz"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, filepath.Join("a", "b", "combine.stats.gz"))
+
+		_, err = os.Stat(filepath.Join(finalDir, ".updated"))
+		So(err, ShouldNotBeNil)
+	})
 }
 
 const minimumDate = 315532801
@@ -43,8 +43,11 @@ func (e Error) Error() string { return string(e) }
 
 const errDupJobs = Error("some of the added jobs were duplicates")
 
+// DefaultRetries is the number of retries NewJob() gives jobs, unless
+// overridden with SetRetries().
+const DefaultRetries uint8 = 30
+
 // some consts for the jobs returned by NewJob().
-const jobRetries uint8 = 30
 const reqRAM = 100
 const reqTime = 10 * time.Second
 const reqCores = 1
@@ -59,6 +62,7 @@ type Scheduler struct {
 	sudo        bool
 	queue       string
 	queuesAvoid string
+	retries     uint8
 }
 
 // New returns a Scheduler that is connected to wr manager using the given
@@ -86,6 +90,7 @@ func New(deployment, cwd, queue, queuesAvoid string, timeout time.Duration, logg
 		queue:       queue,
 		jq:          jq,
 		queuesAvoid: queuesAvoid,
+		retries:     DefaultRetries,
 	}, err
 }
 
@@ -99,6 +104,12 @@ func (s *Scheduler) EnableSudo() {
 	s.sudo = true
 }
 
+// SetRetries overrides the number of retries that NewJob() gives jobs, which
+// otherwise defaults to DefaultRetries.
+func (s *Scheduler) SetRetries(retries uint8) {
+	s.retries = retries
+}
+
 // pickCWD checks the given directory exists, returns an error. If the given
 // dir is blank, returns the current working directory.
 func pickCWD(cwd string) (string, error) {
@@ -137,7 +148,8 @@ func DefaultRequirements() *jqs.Requirements {
 
 // NewJob is a convenience function for creating Jobs. It sets the job's Cwd
 // to the current working directory, sets CwdMatters to true, applies the given
-// Requirements, and sets Retries to 3.
+// Requirements, and sets Retries to DefaultRetries (or the value given to
+// SetRetries()).
 //
 // If this Scheduler had been made with sudo: true, cmd will be prefixed with
 // 'sudo '.
@@ -165,7 +177,7 @@ func (s *Scheduler) NewJob(cmd, repGroup, reqGroup, depGroup, dep string, req *j
 		Requirements: req,
 		DepGroups:    createDepGroups(depGroup),
 		Dependencies: createDependencies(dep),
-		Retries:      jobRetries,
+		Retries:      s.retries,
 		Override:     override,
 	}
 }
@@ -168,6 +168,16 @@ func TestStatFile(t *testing.T) {
 			So(job.Cmd, ShouldEqual, "sudo cmd")
 		})
 
+		Convey("You can make a Scheduler that creates jobs with custom retries", func() {
+			s, err := New(deployment, "", "", "", timeout, logger)
+			So(err, ShouldBeNil)
+			So(s, ShouldNotBeNil)
+			s.SetRetries(5)
+
+			job := s.NewJob("cmd", "rep", "req", "", "", nil)
+			So(job.Retries, ShouldEqual, 5)
+		})
+
 		Convey("You can make a Scheduler with a Req override", func() {
 			s, err := New(deployment, "", "", "", timeout, logger)
 			So(err, ShouldBeNil)
@@ -32,11 +32,14 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"strings"
 	"syscall"
 )
 
 type FileType string
 
+const errPrefixMismatch = Error("path does not start with the prefix to trim")
+
 // bytesPerBlock is the number of bytes in a block of st_blocks. st_blksize is
 // unrelated.
 // See http://www.gnu.org/software/libc/manual/html_node/Attribute-Meanings.html
@@ -89,6 +92,12 @@ func (fs *FileStats) correctSize(stat *syscall.Stat_t) {
 	}
 }
 
+// useBlocksForSize sets our Size to stat.Blocks*bytesPerBlock, ie. the disk
+// space actually allocated.
+func (fs *FileStats) useBlocksForSize(stat *syscall.Stat_t) {
+	fs.Size = stat.Blocks * bytesPerBlock
+}
+
 // File interprets the given file info to produce a FileStats.
 //
 // You provide the absolute path to the file so that QuotedPath can be
@@ -139,7 +148,7 @@ func nonRegularTypeToFileType(fileMode fs.FileMode) FileType {
 		return FileTypeSocket
 	case fs.ModeDevice:
 		return FileTypeBlock
-	case fs.ModeCharDevice:
+	case fs.ModeDevice | fs.ModeCharDevice, fs.ModeCharDevice:
 		return FileTypeChar
 	case fs.ModeNamedPipe:
 		return FileTypeFIFO
@@ -148,14 +157,66 @@ func nonRegularTypeToFileType(fileMode fs.FileMode) FileType {
 	}
 }
 
+// FileOperationConfig can be supplied to FileOperationWithConfig() to alter
+// the FileStats that get output.
+type FileOperationConfig struct {
+	// DirBlocks makes directories have a Size of their allocated blocks,
+	// instead of their apparent size (typically a fixed 4096 bytes).
+	DirBlocks bool
+
+	// UIDMap and GIDMap, if set, translate the UID and GID of each file to the
+	// mapped id. Unmapped ids are left unchanged.
+	UIDMap IDMap
+	GIDMap IDMap
+
+	// TrimPrefix, if set, is removed from the start of each path and replaced
+	// with AddPrefix. Paths that don't start with TrimPrefix result in an
+	// error and are not output.
+	TrimPrefix string
+	AddPrefix  string
+}
+
 // FileOperation returns an Operation that can be used with Paths that calls
 // File() on each path the Operation receives and outputs the ToString() value
 // to the given output file.
 func FileOperation(output *os.File) Operation {
+	return FileOperationWithConfig(output, FileOperationConfig{})
+}
+
+// FileOperationWithConfig is like FileOperation(), but the FileStats are
+// altered according to the given config before being output.
+func FileOperationWithConfig(output *os.File, config FileOperationConfig) Operation {
 	return func(path string, info fs.FileInfo) error {
 		f := File(path, info)
+		if err := config.apply(&f, info); err != nil {
+			return err
+		}
+
 		_, errw := f.WriteTo(output)
 
 		return errw
 	}
 }
+
+// apply alters the given FileStats for the given info according to our
+// config.
+func (c FileOperationConfig) apply(f *FileStats, info fs.FileInfo) error {
+	if c.TrimPrefix != "" {
+		if !strings.HasPrefix(f.Path, c.TrimPrefix) {
+			return fmt.Errorf("%w: %s", errPrefixMismatch, f.Path)
+		}
+
+		f.Path = c.AddPrefix + strings.TrimPrefix(f.Path, c.TrimPrefix)
+	}
+
+	if c.DirBlocks && f.Type == FileTypeDir {
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			f.useBlocksForSize(stat)
+		}
+	}
+
+	f.UID = c.UIDMap.Map(f.UID)
+	f.GID = c.GIDMap.Map(f.GID)
+
+	return nil
+}
@@ -26,8 +26,10 @@
 package stat
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -67,6 +69,7 @@ func TestStatFile(t *testing.T) {
 		So(modeToType(fs.ModeSocket), ShouldEqual, "s")
 		So(modeToType(fs.ModeDevice), ShouldEqual, "b")
 		So(modeToType(fs.ModeCharDevice), ShouldEqual, "c")
+		So(modeToType(fs.ModeDevice|fs.ModeCharDevice), ShouldEqual, "c")
 		So(modeToType(fs.ModeNamedPipe), ShouldEqual, "F")
 		So(modeToType(fs.ModeIrregular), ShouldEqual, "X")
 	})
@@ -98,9 +101,111 @@ func TestStatFile(t *testing.T) {
 				testFileStats(link, 0, "l")
 			})
 		})
+
+		Convey("for a FIFO", func() {
+			fifo := filepath.Join(dir, "fifo")
+			err := syscall.Mkfifo(fifo, 0600)
+			So(err, ShouldBeNil)
+
+			testFileStats(fifo, 0, "F")
+		})
+
+		Convey("for a socket", func() {
+			sock := filepath.Join(dir, "sock")
+			l, err := net.Listen("unix", sock)
+			So(err, ShouldBeNil)
+
+			defer l.Close()
+
+			testFileStats(sock, 0, "s")
+		})
+
+		Convey("for a character device", func() {
+			testFileStats("/dev/null", 0, "c")
+		})
+	})
+
+	Convey("FileOperationWithConfig() can report directory sizes from their blocks", t, func() {
+		dir := t.TempDir()
+		out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+		So(err, ShouldBeNil)
+
+		info, err := os.Lstat(dir)
+		So(err, ShouldBeNil)
+
+		info = &blocksInfo{FileInfo: info, stat: &syscall.Stat_t{Size: 4096, Blocks: 16}}
+
+		readSize := func() string {
+			_, err = out.Seek(0, 0)
+			So(err, ShouldBeNil)
+
+			content, errr := os.ReadFile(out.Name())
+			So(errr, ShouldBeNil)
+
+			err = out.Truncate(0)
+			So(err, ShouldBeNil)
+
+			return strings.Split(string(content), "\t")[1]
+		}
+
+		err = FileOperation(out)(dir, info)
+		So(err, ShouldBeNil)
+		So(readSize(), ShouldEqual, "4096")
+
+		err = FileOperationWithConfig(out, FileOperationConfig{DirBlocks: true})(dir, info)
+		So(err, ShouldBeNil)
+		So(readSize(), ShouldEqual, "8192")
+	})
+
+	Convey("FileOperationConfig can map UIDs and GIDs", t, func() {
+		var sb strings.Builder
+
+		info, err := os.Lstat(t.TempDir())
+		So(err, ShouldBeNil)
+
+		info = &blocksInfo{FileInfo: info, stat: &syscall.Stat_t{Uid: 5, Gid: 6}}
+
+		config := FileOperationConfig{UIDMap: IDMap{5: 50}, GIDMap: IDMap{7: 70}}
+		f := File("/a", info)
+		So(config.apply(&f, info), ShouldBeNil)
+		_, err = f.WriteTo(&sb)
+		So(err, ShouldBeNil)
+		So(strings.Split(sb.String(), "\t")[2:4], ShouldResemble, []string{"50", "6"})
 	})
+
+	Convey("FileOperationConfig can replace path prefixes", t, func() {
+		info, err := os.Lstat(t.TempDir())
+		So(err, ShouldBeNil)
+
+		config := FileOperationConfig{TrimPrefix: "/old/", AddPrefix: "/new/"}
+
+		for path, expected := range map[string]string{
+			"/old/":      "/new/",
+			"/old/a/b":   "/new/a/b",
+			"/old/a/old": "/new/a/old",
+		} {
+			f := File(path, info)
+			So(config.apply(&f, info), ShouldBeNil)
+			So(f.Path, ShouldEqual, expected)
+		}
+
+		f := File("/older/a", info)
+		err = config.apply(&f, info)
+		So(errors.Is(err, errPrefixMismatch), ShouldBeTrue)
+	})
+}
+
+// blocksInfo is a FileInfo that returns a custom Stat_t from Sys() and reports
+// that same Stat_t's Size.
+type blocksInfo struct {
+	fs.FileInfo
+	stat *syscall.Stat_t
 }
 
+func (b *blocksInfo) Size() int64 { return b.stat.Size }
+
+func (b *blocksInfo) Sys() any { return b.stat }
+
 func testFileStats(path string, size int64, filetype string) {
 	info, err := os.Lstat(path)
 	So(err, ShouldBeNil)
@@ -0,0 +1,104 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package stat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	idMapCols        = 2
+	errBadIDMapLine  = Error("id map line does not have 2 columns")
+	errIDMapDupeLine = Error("id map has the same source id more than once")
+)
+
+// IDMap maps source UIDs or GIDs to target ones.
+type IDMap map[uint32]uint32
+
+// ParseIDMap parses the given reader as an id mapping file, which has 2
+// whitespace separated columns per line: a source id and the target id it
+// should be mapped to. The file can have blank lines and comment lines that
+// begin with #, which will be ignored.
+func ParseIDMap(r io.Reader) (IDMap, error) {
+	m := make(IDMap)
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if err := m.addLine(text); err != nil {
+			return nil, fmt.Errorf("error on line %d: %w", line, err)
+		}
+	}
+
+	return m, scanner.Err()
+}
+
+// addLine parses the given non-blank, non-comment line and adds its mapping.
+func (m IDMap) addLine(text string) error {
+	cols := strings.Fields(text)
+	if len(cols) != idMapCols {
+		return errBadIDMapLine
+	}
+
+	from, err := strconv.ParseUint(cols[0], 10, 32)
+	if err != nil {
+		return err
+	}
+
+	to, err := strconv.ParseUint(cols[1], 10, 32)
+	if err != nil {
+		return err
+	}
+
+	if _, exists := m[uint32(from)]; exists {
+		return errIDMapDupeLine
+	}
+
+	m[uint32(from)] = uint32(to)
+
+	return nil
+}
+
+// Map returns the target id for the given id, or the given id if it isn't
+// mapped.
+func (m IDMap) Map(id uint32) uint32 {
+	if to, ok := m[id]; ok {
+		return to
+	}
+
+	return id
+}
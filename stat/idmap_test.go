@@ -0,0 +1,67 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package stat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIDMap(t *testing.T) {
+	Convey("You can parse an id map file", t, func() {
+		m, err := ParseIDMap(strings.NewReader("# comment\n\n1000 2000\n 1001\t2001 \n"))
+		So(err, ShouldBeNil)
+		So(m, ShouldResemble, IDMap{1000: 2000, 1001: 2001})
+
+		Convey("and map ids with it, passing through unmapped ids", func() {
+			So(m.Map(1000), ShouldEqual, 2000)
+			So(m.Map(1001), ShouldEqual, 2001)
+			So(m.Map(3), ShouldEqual, 3)
+
+			var nilMap IDMap
+
+			So(nilMap.Map(1000), ShouldEqual, 1000)
+		})
+	})
+
+	Convey("Invalid id map files can't be parsed", t, func() {
+		_, err := ParseIDMap(strings.NewReader("1000 2000\n1001\n"))
+		So(errors.Is(err, errBadIDMapLine), ShouldBeTrue)
+		So(err.Error(), ShouldContainSubstring, "line 2")
+
+		_, err = ParseIDMap(strings.NewReader("1000 2000\n1000 2001\n"))
+		So(errors.Is(err, errIDMapDupeLine), ShouldBeTrue)
+
+		_, err = ParseIDMap(strings.NewReader("a 2000\n"))
+		So(err, ShouldNotBeNil)
+
+		_, err = ParseIDMap(strings.NewReader("1 -2\n"))
+		So(err, ShouldNotBeNil)
+	})
+}
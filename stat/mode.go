@@ -0,0 +1,59 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package stat
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"syscall"
+)
+
+// permissionBits are the bits of st_mode that hold the permissions, including
+// setuid, setgid and sticky.
+const permissionBits = 0o7777
+
+// ModeOperation returns an Operation that can be used with Paths that outputs
+// a line for each path the Operation receives to the given output, consisting
+// of the quoted path, its FileType and its permission bits in octal, tab
+// separated.
+func ModeOperation(output io.Writer) Operation {
+	return func(absPath string, info fs.FileInfo) error {
+		_, err := fmt.Fprintf(output, "%q\t%s\t%04o\n", absPath, modeToType(info.Mode()), permissions(info))
+
+		return err
+	}
+}
+
+// permissions returns the permission bits of the given info, preferring the
+// raw st_mode if available.
+func permissions(info fs.FileInfo) uint32 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return stat.Mode & permissionBits
+	}
+
+	return uint32(info.Mode().Perm())
+}
@@ -0,0 +1,66 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package stat
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestModeOperation(t *testing.T) {
+	Convey("ModeOperation outputs the type and permission bits of each path", t, func() {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "world")
+
+		err := os.WriteFile(file, []byte("a"), 0600)
+		So(err, ShouldBeNil)
+
+		err = os.Chmod(file, 0666)
+		So(err, ShouldBeNil)
+
+		err = os.Chmod(dir, os.ModeSticky|0777)
+		So(err, ShouldBeNil)
+
+		var sb strings.Builder
+
+		op := ModeOperation(&sb)
+
+		for _, path := range []string{dir, file} {
+			info, errl := os.Lstat(path)
+			So(errl, ShouldBeNil)
+
+			err = op(path, info)
+			So(err, ShouldBeNil)
+		}
+
+		So(sb.String(), ShouldEqual, strconv.Quote(dir)+"\td\t1777\n"+strconv.Quote(file)+"\tf\t0666\n")
+	})
+}
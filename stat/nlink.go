@@ -0,0 +1,54 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package stat
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"syscall"
+)
+
+// NlinkOperation returns an Operation that can be used with Paths that outputs
+// a line to the given output for each non-directory path the Operation receives
+// that has more than threshold hard links. Each line consists of the quoted
+// path, the number of hard links, the inode and the device id, tab separated.
+func NlinkOperation(output io.Writer, threshold uint64) Operation {
+	return func(absPath string, info fs.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok || uint64(stat.Nlink) <= threshold { //nolint:unconvert
+			return nil
+		}
+
+		_, err := fmt.Fprintf(output, "%q\t%d\t%d\t%d\n", absPath, stat.Nlink, stat.Ino, stat.Dev)
+
+		return err
+	}
+}
@@ -0,0 +1,80 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package stat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNlinkOperation(t *testing.T) {
+	Convey("NlinkOperation outputs files with more hard links than a threshold", t, func() {
+		dir := t.TempDir()
+		shared := filepath.Join(dir, "shared")
+		single := filepath.Join(dir, "single")
+
+		So(os.WriteFile(shared, []byte("a"), 0600), ShouldBeNil)
+		So(os.WriteFile(single, []byte("a"), 0600), ShouldBeNil)
+
+		for i := range 3 {
+			So(os.Link(shared, filepath.Join(dir, fmt.Sprintf("link%d", i))), ShouldBeNil)
+		}
+
+		for _, sub := range []string{"a", "b", "c"} {
+			So(os.Mkdir(filepath.Join(dir, sub), 0700), ShouldBeNil)
+		}
+
+		info, err := os.Lstat(shared)
+		So(err, ShouldBeNil)
+
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		So(ok, ShouldBeTrue)
+
+		expected := fmt.Sprintf("%s\t4\t%d\t%d\n", strconv.Quote(shared), stat.Ino, stat.Dev)
+
+		for threshold, want := range map[uint64]string{1: expected, 3: expected, 4: ""} {
+			var sb strings.Builder
+
+			op := NlinkOperation(&sb, threshold)
+
+			for _, path := range []string{dir, shared, single} {
+				info, err = os.Lstat(path)
+				So(err, ShouldBeNil)
+
+				So(op(path, info), ShouldBeNil)
+			}
+
+			So(sb.String(), ShouldEqual, want)
+		}
+	})
+}
@@ -30,7 +30,9 @@ import (
 	"errors"
 	"io"
 	"io/fs"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -59,25 +61,41 @@ type Paths struct {
 	ops             map[string]Operation
 	ScanTimeout     time.Duration
 	reporters       map[string]*reporter.Reporter
+	failFast        bool
+	skipped         int
+	canonicalise    bool
 }
 
 type PathsConfig struct {
 	Logger          log15.Logger
 	ReportFrequency time.Duration
 	ScanTimeout     time.Duration
+
+	// FailFast makes Scan() return the error from the first Lstat that fails,
+	// instead of logging it and skipping that path.
+	FailFast bool
+
+	// Canonicalise makes Scan() clean each path (see filepath.Clean(), though
+	// any trailing slash is kept) before passing it to your Operations.
+	// Symlinks are not resolved, so that paths stay in the order they were
+	// given.
+	Canonicalise bool
 }
 
 // NewPaths returns a Paths that will use the given Statter to do the Lstat
 // calls and log issues to any configured logger. If you configure a
 // reportFrequency greater than 0, then timings for the lstats and your
 // operations will also be logged. You can also configure a MaxTime that a
-// Scan() can run for before it fails.
+// Scan() can run for before it fails, and if Scan() should fail on the first
+// Lstat error.
 func NewPaths(statter Statter, pathsConfig PathsConfig) *Paths {
 	return &Paths{
 		statter:         statter,
 		logger:          pathsConfig.Logger,
 		reportFrequency: pathsConfig.ReportFrequency,
 		ScanTimeout:     pathsConfig.ScanTimeout,
+		failFast:        pathsConfig.FailFast,
+		canonicalise:    pathsConfig.Canonicalise,
 		ops:             make(map[string]Operation),
 		reporters:       make(map[string]*reporter.Reporter),
 	}
@@ -103,8 +121,10 @@ func (p *Paths) AddOperation(name string, op Operation) error {
 // Scan scans through the given reader which should consist of quoted absolute
 // file path per line. It calls our Statter.Lstat() on each, and passes the
 // absolute path and FileInfo to any Operation callbacks you've added. Errors
-// from the Statter are normally ignored, with the exeption of
-// StatterWithTimeout's failure due to too many consecutive timeouts in a row.
+// from the Statter are normally logged and the path skipped (see Skipped()),
+// with the exeption of StatterWithTimeout's failure due to too many consecutive
+// timeouts in a row. If FailFast was configured, any Statter error is returned
+// immediately instead.
 //
 // Operations are run concurrently (so should not do something like write to the
 // same file) and their errors logged, but otherwise ignored.
@@ -122,6 +142,7 @@ func (p *Paths) Scan(paths io.Reader) error {
 	p.startReporting()
 
 	endTime := time.Now().Add(p.ScanTimeout)
+	p.skipped = 0
 
 	err := p.lstatEachPath(scanner, r, endTime)
 	if err != nil {
@@ -149,6 +170,10 @@ func (p *Paths) lstatEachPath(scanner *bufio.Scanner, r *reporter.Reporter, //no
 			return erru
 		}
 
+		if p.canonicalise {
+			path = canonicalPath(path)
+		}
+
 		info, errt := p.timeLstat(r, path)
 
 		errWg := p.waitUntilWGOrMaxTime(&wg, endTime)
@@ -156,9 +181,11 @@ func (p *Paths) lstatEachPath(scanner *bufio.Scanner, r *reporter.Reporter, //no
 			return errWg
 		}
 
-		if errors.Is(errt, errLstatConsecFails) {
+		if errors.Is(errt, errLstatConsecFails) || (errt != nil && p.failFast) {
 			return errt
 		} else if errt != nil {
+			p.skip(path, errt)
+
 			continue
 		}
 
@@ -191,6 +218,31 @@ func (p *Paths) waitUntilWGOrMaxTime(wg *sync.WaitGroup, endTime time.Time) erro
 	}
 }
 
+// canonicalPath returns the given path cleaned, keeping any trailing slash.
+func canonicalPath(path string) string {
+	canonical := filepath.Clean(path)
+
+	if strings.HasSuffix(path, "/") && canonical != "/" {
+		canonical += "/"
+	}
+
+	return canonical
+}
+
+// skip logs that the given path is being skipped due to the given error, and
+// counts it.
+func (p *Paths) skip(path string, err error) {
+	p.skipped++
+
+	p.logger.Warn("skipping path", "path", path, "err", err)
+}
+
+// Skipped returns the number of paths that the last Scan() skipped because
+// their Lstat failed.
+func (p *Paths) Skipped() int {
+	return p.skipped
+}
+
 // startReporting calls StartReporting on all our reporters.
 func (p *Paths) startReporting() {
 	if p.reportFrequency <= 0 {
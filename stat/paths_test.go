@@ -88,9 +88,99 @@ func TestPaths(t *testing.T) {
 				So(buff.String(), ShouldContainSubstring, `lvl=info msg="report since last" op=lstat count=`)
 				So(buff.String(), ShouldContainSubstring, `lvl=info msg="report overall" op=lstat count=3`)
 				So(buff.String(), ShouldContainSubstring, `lvl=warn msg="report failed" op=lstat count=2`)
+
+				So(p.Skipped(), ShouldEqual, 2)
+				So(buff.String(), ShouldContainSubstring, `lvl=warn msg="skipping path" path=/foo/bar`)
+			})
+		})
+
+		Convey("Paths can be canonicalised when configured", func() {
+			dir, err := filepath.EvalSymlinks(t.TempDir())
+			So(err, ShouldBeNil)
+
+			realDir := filepath.Join(dir, "realDir")
+			So(os.MkdirAll(filepath.Join(realDir, "sub"), 0755), ShouldBeNil)
+			So(os.WriteFile(filepath.Join(realDir, "file"), nil, 0600), ShouldBeNil)
+			So(os.Symlink(realDir, filepath.Join(dir, "link")), ShouldBeNil)
+
+			input := ""
+
+			for _, path := range []string{
+				realDir + "/../realDir/file", dir + "/link/file", realDir + "/./sub/", dir + "/link", dir + "//realDir/sub/..",
+			} {
+				input += strconv.Quote(path) + "\n"
+			}
+
+			var paths []string
+
+			pConfig.Canonicalise = true
+			p = NewPaths(s, pConfig)
+			err = p.AddOperation("paths", func(absPath string, _ fs.FileInfo) error {
+				paths = append(paths, absPath)
+
+				return nil
+			})
+			So(err, ShouldBeNil)
+
+			err = p.Scan(strings.NewReader(input))
+			So(err, ShouldBeNil)
+			So(paths, ShouldResemble, []string{
+				realDir + "/file", dir + "/link/file", realDir + "/sub/", dir + "/link", realDir,
+			})
+
+			Convey("without resolving symlinks, so sorted input stays sorted", func() {
+				So(os.Symlink(filepath.Join(dir, "realDir"), filepath.Join(dir, "b")), ShouldBeNil)
+				So(os.WriteFile(filepath.Join(dir, "a"), nil, 0600), ShouldBeNil)
+				So(os.WriteFile(filepath.Join(dir, "c"), nil, 0600), ShouldBeNil)
+
+				sorted := []string{
+					dir + "/", dir + "/a", dir + "/b/", dir + "/b/file", dir + "/c", dir + "/realDir/", dir + "/realDir/file",
+				}
+
+				input = ""
+
+				for _, path := range sorted {
+					input += strconv.Quote(path) + "\n"
+				}
+
+				paths = nil
+
+				err = p.Scan(strings.NewReader(input))
+				So(err, ShouldBeNil)
+				So(paths, ShouldResemble, sorted)
 			})
 		})
 
+		Convey("Unreadable paths are skipped, unless FailFast is configured", func() {
+			pathEmpty, pathContent1, pathContent2 := createTestFiles(t)
+			unreadable := filepath.Join(filepath.Dir(pathEmpty), "unreadable")
+			input := strconv.Quote(pathEmpty) + "\n" + strconv.Quote(unreadable) + "\n" +
+				strconv.Quote(pathContent1) + "\n" + strconv.Quote(pathContent2) + "\n"
+
+			s.SetLstat(func(path string) (fs.FileInfo, error) {
+				if path == unreadable {
+					return nil, fs.ErrPermission
+				}
+
+				return os.Lstat(path)
+			})
+
+			checkN, _ := addTestOperations(p)
+
+			err := p.Scan(strings.NewReader(input))
+			So(err, ShouldBeNil)
+			So(*checkN, ShouldEqual, 3)
+			So(p.Skipped(), ShouldEqual, 1)
+
+			pConfig.FailFast = true
+			p = NewPaths(s, pConfig)
+			checkN, _ = addTestOperations(p)
+
+			err = p.Scan(strings.NewReader(input))
+			So(err, ShouldEqual, fs.ErrPermission)
+			So(*checkN, ShouldEqual, 1)
+		})
+
 		Convey("Given a small max failure count, scan fails with consecutive failures", func() {
 			s = WithTimeout(1*time.Nanosecond, statterRetries, 2, l)
 			p = NewPaths(s, pConfig)
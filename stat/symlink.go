@@ -0,0 +1,67 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package stat
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+)
+
+const (
+	symlinkStatusOK     = "ok"
+	symlinkStatusBroken = "broken"
+)
+
+// SymlinkOperation returns an Operation that can be used with Paths that, for
+// each symlink the Operation receives, outputs a line to the given output
+// consisting of the quoted path of the symlink, the quoted target (as returned
+// by readlink) and either "ok" or "broken" depending on whether the target
+// can be statted, tab separated. Other file types are ignored.
+func SymlinkOperation(output io.Writer) Operation {
+	return func(absPath string, info fs.FileInfo) error {
+		if info.Mode()&fs.ModeSymlink == 0 {
+			return nil
+		}
+
+		target, err := os.Readlink(absPath)
+		if err != nil {
+			return err
+		}
+
+		status := symlinkStatusOK
+
+		if _, err = os.Stat(absPath); err != nil {
+			status = symlinkStatusBroken
+		}
+
+		_, err = fmt.Fprintf(output, "%s\t%s\t%s\n", strconv.Quote(absPath), strconv.Quote(target), status)
+
+		return err
+	}
+}
@@ -0,0 +1,70 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package stat
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSymlinkOperation(t *testing.T) {
+	Convey("SymlinkOperation reports valid and broken symlinks, ignoring other files", t, func() {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "file")
+		valid := filepath.Join(dir, "valid")
+		broken := filepath.Join(dir, "broken")
+
+		err := os.WriteFile(file, []byte("a"), 0600)
+		So(err, ShouldBeNil)
+
+		err = os.Symlink(file, valid)
+		So(err, ShouldBeNil)
+
+		err = os.Symlink("missing", broken)
+		So(err, ShouldBeNil)
+
+		_, l := newLogger()
+		p := NewPaths(WithTimeout(time.Second, 1, 1, l), PathsConfig{Logger: l})
+
+		var sb strings.Builder
+
+		err = p.AddOperation("symlink", SymlinkOperation(&sb))
+		So(err, ShouldBeNil)
+
+		err = p.Scan(strings.NewReader(strconv.Quote(broken) + "\n" +
+			strconv.Quote(file) + "\n" + strconv.Quote(valid) + "\n"))
+		So(err, ShouldBeNil)
+
+		So(sb.String(), ShouldEqual, strconv.Quote(broken)+"\t\"missing\"\tbroken\n"+
+			strconv.Quote(valid)+"\t"+strconv.Quote(file)+"\tok\n")
+	})
+}
@@ -114,6 +114,7 @@ type Files struct {
 	Paths    []string
 	filesI   int
 	filesMax int
+	entries  int
 	mu       sync.RWMutex
 	mus      []sync.Mutex
 }
@@ -122,7 +123,8 @@ type Files struct {
 // PathsCallback function suitable for passing to New().
 //
 // This creates n output files in outDir, and writes the walk paths to those
-// files 1 per line in a round-robin.
+// files 1 per line in a round-robin. Since Walk() sends paths in sorted order,
+// each output file will also be sorted.
 //
 // The output file paths can be found in the Paths property.
 //
@@ -183,6 +185,7 @@ func (f *Files) WritePaths() PathCallback {
 func (f *Files) writePath(path []byte) error {
 	i := f.filesI
 	f.filesI++
+	f.entries++
 
 	if f.filesI == f.filesMax {
 		f.filesI = 0
@@ -196,6 +199,12 @@ func (f *Files) writePath(path []byte) error {
 	return err
 }
 
+// Entries returns the number of paths that have been written to our output
+// files.
+func (f *Files) Entries() int {
+	return f.entries
+}
+
 // Close should be called after Walk()ing to close all the output files.
 func (f *Files) Close() error {
 	for _, file := range f.files {
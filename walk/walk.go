@@ -58,6 +58,7 @@ type Walker struct {
 	pathCB         PathCallback
 	sendDirs       bool
 	ignoreSymlinks bool
+	pruneDirs      map[string]struct{}
 }
 
 // New creates a new Walker that can Walk() a filesystem and send all the
@@ -75,6 +76,29 @@ func New(cb PathCallback, includDirs, ignoreSymlinks bool) *Walker {
 	}
 }
 
+// PruneDirs makes future Walk()s not descend in to directories with any of the
+// given basenames, such as ".git" or "node_modules". The directories themselves
+// will still be sent to your PathCallback if you set includeDirs in New().
+func (w *Walker) PruneDirs(names ...string) {
+	w.pruneDirs = make(map[string]struct{}, len(names))
+
+	for _, name := range names {
+		w.pruneDirs[name+"/"] = struct{}{}
+	}
+}
+
+// pruned returns true if the given directory entry has a basename supplied to
+// PruneDirs().
+func (w *Walker) pruned(d *Dirent) bool {
+	if len(w.pruneDirs) == 0 {
+		return false
+	}
+
+	_, ok := w.pruneDirs[string(d.bytes())]
+
+	return ok
+}
+
 // ErrorCallback is a callback function you supply Walker.Walk(), and it
 // will be provided problematic paths encountered during the walk.
 type ErrorCallback func(path string, err error)
@@ -190,12 +214,12 @@ Loop:
 				errCB(string(pathBuffer[:l]), err)
 			}
 
-			go scanChildDirs(ctx, requestCh, request, children)
+			go w.scanChildDirs(ctx, requestCh, request, children)
 		}
 	}
 }
 
-func scanChildDirs(ctx context.Context, requestCh chan *Dirent, request, children *Dirent) {
+func (w *Walker) scanChildDirs(ctx context.Context, requestCh chan *Dirent, request, children *Dirent) {
 	marker := getDirent(0)
 	marker.next = request.next
 	marker.parent = request
@@ -205,7 +229,7 @@ func scanChildDirs(ctx context.Context, requestCh chan *Dirent, request, childre
 	for r := request.next; r != marker; {
 		next := r.next
 
-		if r.IsDir() {
+		if r.IsDir() && !w.pruned(r) {
 			r.markNotReady()
 
 			select {
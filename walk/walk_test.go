@@ -77,6 +77,7 @@ func TestWalk(t *testing.T) {
 
 			err = files.Close()
 			So(err, ShouldBeNil)
+			So(files.Entries(), ShouldEqual, len(expectedPaths))
 
 			splitExpected := make([][]string, n)
 			splitI := 0
@@ -203,6 +204,86 @@ func TestWalk(t *testing.T) {
 			So(gotInode, ShouldEqual, u.Ino)
 		})
 
+		Convey("Output is lexically sorted by full path, even with names sorting around '/'", func() {
+			dir := t.TempDir()
+
+			for _, sub := range []string{"a/b", "a/a-b", "a-b/c", "a!/d", "a.b/e"} {
+				So(os.MkdirAll(filepath.Join(dir, sub), os.ModePerm), ShouldBeNil)
+			}
+
+			for _, file := range []string{"a0", "a-", "a/b.txt", "a/b/0"} {
+				So(os.WriteFile(filepath.Join(dir, file), nil, userOnlyPerm), ShouldBeNil)
+			}
+
+			files, err := NewFiles(outDir, 3)
+			So(err, ShouldBeNil)
+
+			var paths []string
+
+			write := files.WritePaths()
+			w := New(func(entry *Dirent) error {
+				paths = append(paths, string(entry.Bytes()))
+
+				return write(entry)
+			}, true, false)
+
+			err = w.Walk(dir, cb)
+			So(err, ShouldBeNil)
+			So(files.Close(), ShouldBeNil)
+			So(len(paths), ShouldEqual, 14)
+			So(slices.IsSorted(paths), ShouldBeTrue)
+
+			for _, path := range files.Paths {
+				content, errr := os.ReadFile(path)
+				So(errr, ShouldBeNil)
+
+				var unquoted []string
+
+				for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+					p, errr := strconv.Unquote(line)
+					So(errr, ShouldBeNil)
+
+					unquoted = append(unquoted, p)
+				}
+
+				So(slices.IsSorted(unquoted), ShouldBeTrue)
+			}
+		})
+
+		Convey("You can prune directories by name", func() {
+			for _, dir := range []string{"node_modules/a/b", "c/node_modules/d", "c/.git"} {
+				So(os.MkdirAll(filepath.Join(walkDir, "1", dir), os.ModePerm), ShouldBeNil)
+			}
+
+			So(os.WriteFile(filepath.Join(walkDir, "1", "node_modules", "f"), nil, 0600), ShouldBeNil)
+			So(os.WriteFile(filepath.Join(walkDir, "1", "c", "f"), nil, 0600), ShouldBeNil)
+
+			var paths []string
+
+			w := New(func(entry *Dirent) error {
+				paths = append(paths, string(entry.Bytes()))
+
+				return nil
+			}, true, false)
+			w.PruneDirs("node_modules", ".git")
+
+			err := w.Walk(filepath.Join(walkDir, "1"), cb)
+			So(err, ShouldBeNil)
+			So(len(walkErrors), ShouldEqual, 0)
+
+			So(paths, ShouldContain, walkDir+"/1/c/f")
+
+			var prunedPaths []string
+
+			for _, path := range paths {
+				if strings.Contains(path, "node_modules") || strings.Contains(path, ".git") {
+					prunedPaths = append(prunedPaths, strings.TrimPrefix(path, walkDir))
+				}
+			}
+
+			So(prunedPaths, ShouldResemble, []string{"/1/c/.git/", "/1/c/node_modules/", "/1/node_modules/"})
+		})
+
 		Convey("You can print just the files", func() {
 			expected := make([]string, 0, len(expectedPaths))
 